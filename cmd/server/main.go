@@ -13,6 +13,13 @@ import (
 	"github.com/MH-PAVEL/uni-backend-go/internal/config"
 	"github.com/MH-PAVEL/uni-backend-go/internal/database"
 	_ "github.com/MH-PAVEL/uni-backend-go/internal/docs"
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+	"github.com/MH-PAVEL/uni-backend-go/internal/jwtkeys"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/oidc"
+	"github.com/MH-PAVEL/uni-backend-go/internal/password"
+	"github.com/MH-PAVEL/uni-backend-go/internal/sms"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
 
 	"github.com/MH-PAVEL/uni-backend-go/internal/routes"
 )
@@ -26,12 +33,39 @@ import (
 // @in header
 // @name Authorization
 // @description Use: "Bearer <access_token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key for partners and batch jobs, on endpoints that accept one
 
 func main() {
 	// Load env and config
 	config.LoadEnv()
 	cfg := config.LoadConfig()
 
+	// Load access token signing keys
+	if err := jwtkeys.Init(cfg.Auth); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Configure outgoing mail
+	if err := mailer.Init(cfg.Mail); err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+
+	// Configure outgoing SMS
+	if err := sms.Init(cfg.SMS); err != nil {
+		log.Fatalf("Failed to configure SMS provider: %v", err)
+	}
+
+	// Configure password policy (and breached password list)
+	if err := password.Init(cfg.Auth); err != nil {
+		log.Fatalf("Failed to configure password policy: %v", err)
+	}
+
+	// Configure social login providers
+	oidc.Init(cfg)
+
 	// Connect DB
 	_, dbCancel := database.ConnectMongo()
 	defer dbCancel()
@@ -41,6 +75,18 @@ func main() {
 		log.Printf("Warning: Failed to create database indexes: %v", err)
 	}
 
+	// Create or promote the first admin account
+	bootstrapCtx, bootstrapCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := utils.BootstrapAdmin(bootstrapCtx); err != nil {
+		log.Printf("Warning: Failed to bootstrap admin account: %v", err)
+	}
+	bootstrapCancel()
+
+	// Purge accounts whose deletion grace period is over
+	purgeCtx, purgeCancel := context.WithCancel(context.Background())
+	defer purgeCancel()
+	utils.StartAccountPurger(purgeCtx)
+
 	// Global router
 	handler := routes.RegisterRoutes()
 
@@ -80,6 +126,11 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Let signups and emails still running in the background finish
+	if err := handlers.WaitForBackgroundJobs(shutdownCtx); err != nil {
+		log.Printf("Gave up waiting for background jobs: %v", err)
+	}
+
 	// Disconnect database
 	if database.Client != nil {
 		if err := database.Client.Disconnect(shutdownCtx); err != nil {
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -12,11 +14,16 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Auth     AuthConfig
+	Mail     MailConfig
+	SMS      SMSConfig
+	WebAuthn WebAuthnConfig
+	OAuth    OAuthConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port        string
+	Host        string
+	FrontendURL string
 }
 
 type DatabaseConfig struct {
@@ -25,9 +32,113 @@ type DatabaseConfig struct {
 }
 
 type AuthConfig struct {
-	JWTSecret   string
-	AccessTTL   time.Duration
-	RefreshTTL  time.Duration
+	JWTSecret            string
+	AccessTTL            time.Duration
+	RefreshTTL           time.Duration
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	MagicLinkTTL         time.Duration
+
+	// Access token signing. JWTSecret is still used for HMAC-signed
+	// one-time tokens whatever the algorithm.
+	JWTAlgorithm            string // HS256, RS256 or EdDSA
+	JWTPrivateKeyFile       string // PEM, for RS256/EdDSA
+	JWTKeyID                string // defaults to the key's thumbprint
+	JWTVerificationKeyFiles []string
+	JWTAcceptHS256          bool // keep accepting HS256 tokens after switching
+	JWTIssuer               string
+	JWTAudience             string
+	JWTLeeway               time.Duration // clock skew allowed when validating
+	// How long token revocation and account suspension lookups are cached
+	// per instance; changes made on other instances take up to this long
+	// to be seen
+	RevocationCacheTTL time.Duration
+	// Lifetime of the access tokens admins get to act as another user;
+	// they cannot be refreshed
+	ImpersonationTTL time.Duration
+
+	// Password policy
+	PasswordMinLength     int
+	PasswordMaxBytes      int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	BreachedPasswordsFile string // SHA-1 hashes, one per line
+
+	// One-time codes (SMS/email)
+	OTPLength         int
+	OTPTTL            time.Duration
+	OTPMaxAttempts    int
+	OTPResendCooldown time.Duration
+
+	// Two-factor authentication
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	MFAMaxAttempts  int
+
+	// Login lockout: after LoginMaxFailures failed passwords for the same
+	// identifier it is locked for LoginLockoutBase, doubling with every
+	// further failure up to LoginLockoutMax. Counters reset after
+	// LoginFailureWindow without failures.
+	LoginMaxFailures   int
+	LoginLockoutBase   time.Duration
+	LoginLockoutMax    time.Duration
+	LoginFailureWindow time.Duration
+	LoginLockoutNotify bool // email the account owner when it gets locked
+
+	// First admin account, created or promoted at startup while no admin
+	// exists. The password is only needed when the account does not exist.
+	BootstrapAdminEmail    string
+	BootstrapAdminPassword string
+
+	// Account deletion: accounts are purged AccountDeletionGrace after the
+	// user asks, by a job running every AccountPurgeInterval. Accounts
+	// without a password re-authenticate by having logged in within
+	// ReauthMaxAge.
+	AccountDeletionGrace time.Duration
+	AccountPurgeInterval time.Duration
+	ReauthMaxAge         time.Duration
+}
+
+type MailConfig struct {
+	Driver       string // log, file or smtp
+	From         string
+	FileDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type SMSConfig struct {
+	Provider  string // console, file or http
+	Sender    string
+	FilePath  string
+	HTTPURL   string
+	HTTPToken string
+}
+
+type WebAuthnConfig struct {
+	RPID    string // domain passkeys are scoped to
+	RPName  string
+	Origins []string // origins allowed to run ceremonies
+	Timeout time.Duration
+}
+
+type OAuthConfig struct {
+	StateTTL  time.Duration
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig configures one OpenID Connect login provider
+type OIDCProviderConfig struct {
+	Name         string // used in routes, e.g. google
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 var AppConfig *Config
@@ -45,23 +156,90 @@ func LoadConfig() *Config {
 	// Load .env file first
 	LoadEnv()
 	
-	// Parse durations
-	accessTTL, _ := time.ParseDuration(getEnv("ACCESS_TTL", "15m"))
-	refreshTTL, _ := time.ParseDuration(getEnv("REFRESH_TTL", "7d"))
-	
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", ""),
+			Port:        getEnv("SERVER_PORT", "8080"),
+			Host:        getEnv("SERVER_HOST", ""),
+			FrontendURL: strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/"),
 		},
 		Database: DatabaseConfig{
 			URI:  getEnv("MONGO_URI", ""),
 			Name: getEnv("MONGO_DB_NAME", ""),
 		},
 		Auth: AuthConfig{
-			JWTSecret:  getEnv("JWT_SECRET", ""),
-			AccessTTL:  accessTTL,
-			RefreshTTL: refreshTTL,
+			JWTSecret:            getEnv("JWT_SECRET", ""),
+			AccessTTL:            getDuration("ACCESS_TTL", "15m"),
+			RefreshTTL:           getDuration("REFRESH_TTL", "7d"),
+			EmailVerificationTTL: getDuration("EMAIL_VERIFICATION_TTL", "24h"),
+			PasswordResetTTL:     getDuration("PASSWORD_RESET_TTL", "1h"),
+			MagicLinkTTL:         getDuration("MAGIC_LINK_TTL", "15m"),
+
+			JWTAlgorithm:            getEnv("JWT_ALGORITHM", "HS256"),
+			JWTPrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			JWTKeyID:                getEnv("JWT_KEY_ID", ""),
+			JWTVerificationKeyFiles: getList("JWT_VERIFICATION_KEY_FILES", ""),
+			JWTAcceptHS256:          getBool("JWT_ACCEPT_HS256", false),
+			JWTIssuer:               getEnv("JWT_ISSUER", "uni-backend"),
+			JWTAudience:             getEnv("JWT_AUDIENCE", "uni-backend-api"),
+			JWTLeeway:               getDuration("JWT_LEEWAY", "30s"),
+			RevocationCacheTTL:      getDuration("REVOCATION_CACHE_TTL", "10s"),
+			ImpersonationTTL:        getDuration("IMPERSONATION_TTL", "15m"),
+
+			PasswordMinLength:     getInt("PASSWORD_MIN_LENGTH", 8),
+			PasswordMaxBytes:      getInt("PASSWORD_MAX_BYTES", 72),
+			PasswordRequireUpper:  getBool("PASSWORD_REQUIRE_UPPER", false),
+			PasswordRequireLower:  getBool("PASSWORD_REQUIRE_LOWER", false),
+			PasswordRequireDigit:  getBool("PASSWORD_REQUIRE_DIGIT", false),
+			PasswordRequireSymbol: getBool("PASSWORD_REQUIRE_SYMBOL", false),
+			BreachedPasswordsFile: getEnv("BREACHED_PASSWORDS_FILE", ""),
+
+			OTPLength:         getInt("OTP_LENGTH", 6),
+			OTPTTL:            getDuration("OTP_TTL", "5m"),
+			OTPMaxAttempts:    getInt("OTP_MAX_ATTEMPTS", 5),
+			OTPResendCooldown: getDuration("OTP_RESEND_COOLDOWN", "60s"),
+
+			MFAIssuer:       getEnv("MFA_ISSUER", "Uni Backend"),
+			MFAChallengeTTL: getDuration("MFA_CHALLENGE_TTL", "5m"),
+			MFAMaxAttempts:  getInt("MFA_MAX_ATTEMPTS", 5),
+
+			LoginMaxFailures:   getInt("LOGIN_MAX_FAILURES", 5),
+			LoginLockoutBase:   getDuration("LOGIN_LOCKOUT_BASE", "1m"),
+			LoginLockoutMax:    getDuration("LOGIN_LOCKOUT_MAX", "1h"),
+			LoginFailureWindow: getDuration("LOGIN_FAILURE_WINDOW", "1h"),
+			LoginLockoutNotify: getBool("LOGIN_LOCKOUT_NOTIFY", true),
+
+			BootstrapAdminEmail:    strings.TrimSpace(strings.ToLower(getEnv("BOOTSTRAP_ADMIN_EMAIL", ""))),
+			BootstrapAdminPassword: getEnv("BOOTSTRAP_ADMIN_PASSWORD", ""),
+
+			AccountDeletionGrace: getDuration("ACCOUNT_DELETION_GRACE", "14d"),
+			AccountPurgeInterval: getDuration("ACCOUNT_PURGE_INTERVAL", "1h"),
+			ReauthMaxAge:         getDuration("REAUTH_MAX_AGE", "10m"),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+			FileDir:      getEnv("MAIL_FILE_DIR", "tmp/mail"),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		},
+		SMS: SMSConfig{
+			Provider:  getEnv("SMS_PROVIDER", "console"),
+			Sender:    getEnv("SMS_SENDER", ""),
+			FilePath:  getEnv("SMS_FILE_PATH", "tmp/sms.log"),
+			HTTPURL:   getEnv("SMS_HTTP_URL", ""),
+			HTTPToken: getEnv("SMS_HTTP_TOKEN", ""),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", "Uni Backend"),
+			Origins: getList("WEBAUTHN_ORIGINS", strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/")),
+			Timeout: getDuration("WEBAUTHN_TIMEOUT", "5m"),
+		},
+		OAuth: OAuthConfig{
+			StateTTL:  getDuration("OAUTH_STATE_TTL", "10m"),
+			Providers: loadOIDCProviders(strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/")),
 		},
 	}
 
@@ -75,11 +253,50 @@ func LoadConfig() *Config {
 	if config.Auth.JWTSecret == "" {
 		log.Fatal("JWT_SECRET is required")
 	}
+	if config.Mail.Driver == "smtp" && config.Mail.SMTPHost == "" {
+		log.Fatal("SMTP_HOST is required when MAIL_DRIVER=smtp")
+	}
+	if config.SMS.Provider == "http" && config.SMS.HTTPURL == "" {
+		log.Fatal("SMS_HTTP_URL is required when SMS_PROVIDER=http")
+	}
+	if config.Auth.OTPLength < 4 || config.Auth.OTPLength > 10 {
+		log.Fatal("OTP_LENGTH must be between 4 and 10")
+	}
+	for _, p := range config.OAuth.Providers {
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Fatalf("OIDC provider %q needs an issuer and client id", p.Name)
+		}
+	}
 
 	AppConfig = config
 	return config
 }
 
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS. Each one is
+// configured with OIDC_<NAME>_* variables; Google's issuer is known.
+func loadOIDCProviders(frontendURL string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range getList("OIDC_PROVIDERS", "") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		defaultIssuer := ""
+		if name == "google" {
+			defaultIssuer = "https://accounts.google.com"
+		}
+
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			Issuer:       strings.TrimRight(getEnv(prefix+"ISSUER", defaultIssuer), "/"),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", frontendURL+"/oauth/"+name+"/callback"),
+			Scopes:       getList(prefix+"SCOPES", "openid,email,profile"),
+		})
+	}
+	return providers
+}
+
 // getEnv returns environment variable or default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -87,3 +304,56 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDuration parses a duration environment variable. On top of
+// time.ParseDuration units it accepts a whole number of days, e.g. "7d".
+func getDuration(key, defaultValue string) time.Duration {
+	raw := getEnv(key, defaultValue)
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour
+		}
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("%s must be a duration (e.g. 15m, 24h, 7d): %v", key, err)
+	}
+	return d
+}
+
+// getInt parses an integer environment variable
+func getInt(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %v", key, err)
+	}
+	return n
+}
+
+// getBool parses a boolean environment variable (true/false, 1/0)
+func getBool(key string, defaultValue bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatalf("%s must be true or false: %v", key, err)
+	}
+	return b
+}
+
+// getList parses a comma-separated environment variable
+func getList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
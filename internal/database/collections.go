@@ -2,5 +2,17 @@ package database
 
 // Central list of collection names used in the app
 const (
-    UsersCollection = "users"
+    UsersCollection               = "users"
+    SessionsCollection            = "sessions"
+    SecurityEventsCollection      = "security_events"
+    UserTokensCollection          = "user_tokens"
+    OTPCodesCollection            = "otp_codes"
+    WebAuthnCredentialsCollection = "webauthn_credentials"
+    WebAuthnChallengesCollection  = "webauthn_challenges"
+    OAuthStatesCollection         = "oauth_states"
+    RevokedTokensCollection       = "revoked_tokens"
+    LoginAttemptsCollection       = "login_attempts"
+    AuthEventsCollection          = "auth_events"
+    APIKeysCollection             = "api_keys"
+    ImpersonationTokensCollection = "impersonation_tokens"
 )
@@ -71,14 +71,306 @@ func CreateIndexes() error {
 		return fmt.Errorf("failed to create email index: %w", err)
 	}
 
-	// Create unique index on phone
+	// Create index on roles (staff listings, admin bootstrap)
 	_, err = usersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "phone", Value: 1}},
-		Options: options.Index().SetUnique(true),
+		Keys: bson.D{{Key: "roles", Value: 1}},
 	})
 	if err != nil {
+		return fmt.Errorf("failed to create roles index: %w", err)
+	}
+
+	// Create index on scheduled account deletions (purge job)
+	_, err = usersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletionScheduledAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create deletion schedule index: %w", err)
+	}
+
+	// Create unique index on phone. Social login accounts have no phone, so
+	// the index is sparse.
+	if err := ensureSparsePhoneIndex(ctx, usersCollection); err != nil {
 		return fmt.Errorf("failed to create phone index: %w", err)
 	}
 
+	// Create unique index on linked social login identities
+	_, err = usersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"identities.subject": bson.M{"$exists": true},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create identity index: %w", err)
+	}
+
+	sessionsCollection := GetCollection(DbName(), SessionsCollection)
+
+	// Create unique index on the refresh token hash (lookup on refresh/logout)
+	_, err = sessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "refreshTokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session token index: %w", err)
+	}
+
+	// Create index on userId for listing a user's sessions
+	_, err = sessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session user index: %w", err)
+	}
+
+	// Create index on spent refresh token hashes for reuse detection
+	_, err = sessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "spentTokenHashes", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session spent token index: %w", err)
+	}
+
+	// Create TTL index so expired sessions are removed automatically
+	_, err = sessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session expiry index: %w", err)
+	}
+
+	// Create index on security events per user, newest first
+	_, err = GetCollection(DbName(), SecurityEventsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create security event index: %w", err)
+	}
+
+	userTokensCollection := GetCollection(DbName(), UserTokensCollection)
+
+	// Create unique index on the token hash
+	_, err = userTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user token hash index: %w", err)
+	}
+
+	// Create index on userId + purpose for invalidating previous tokens
+	_, err = userTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user token owner index: %w", err)
+	}
+
+	// Create TTL index so expired tokens are removed automatically
+	_, err = userTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user token expiry index: %w", err)
+	}
+
+	otpCodesCollection := GetCollection(DbName(), OTPCodesCollection)
+
+	// Create unique index so each target has one active code per purpose
+	_, err = otpCodesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "purpose", Value: 1}, {Key: "target", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create otp target index: %w", err)
+	}
+
+	// Create TTL index so expired codes are removed automatically
+	_, err = otpCodesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create otp expiry index: %w", err)
+	}
+
+	webAuthnCredentialsCollection := GetCollection(DbName(), WebAuthnCredentialsCollection)
+
+	// Create unique index on the credential id reported by authenticators
+	_, err = webAuthnCredentialsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "credentialId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn credential id index: %w", err)
+	}
+
+	// Create index on userId for listing a user's passkeys
+	_, err = webAuthnCredentialsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn credential user index: %w", err)
+	}
+
+	webAuthnChallengesCollection := GetCollection(DbName(), WebAuthnChallengesCollection)
+
+	// Create unique index on the challenge so each one is used once
+	_, err = webAuthnChallengesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "challenge", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn challenge index: %w", err)
+	}
+
+	// Create TTL index so abandoned ceremonies are removed automatically
+	_, err = webAuthnChallengesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn challenge expiry index: %w", err)
+	}
+
+	oauthStatesCollection := GetCollection(DbName(), OAuthStatesCollection)
+
+	// Create unique index on the state hash (lookup on callback)
+	_, err = oauthStatesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "stateHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create oauth state index: %w", err)
+	}
+
+	// Create TTL index so abandoned logins are removed automatically
+	_, err = oauthStatesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create oauth state expiry index: %w", err)
+	}
+
+	revokedTokensCollection := GetCollection(DbName(), RevokedTokensCollection)
+
+	// Create unique index on the token id (checked on every request)
+	_, err = revokedTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "jti", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create revoked token index: %w", err)
+	}
+
+	// Create TTL index so entries go away once the token would have expired
+	_, err = revokedTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create revoked token expiry index: %w", err)
+	}
+
+	loginAttemptsCollection := GetCollection(DbName(), LoginAttemptsCollection)
+
+	// Create unique index so each identifier has one failure counter
+	_, err = loginAttemptsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identifierHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create login attempt index: %w", err)
+	}
+
+	// Create TTL index so counters reset once the failure window has passed
+	_, err = loginAttemptsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create login attempt expiry index: %w", err)
+	}
+
+	authEventsCollection := GetCollection(DbName(), AuthEventsCollection)
+
+	// Create index on auth events per user, newest first (recent activity)
+	_, err = authEventsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create auth event user index: %w", err)
+	}
+
+	// Create index on auth events by time (admin queries)
+	_, err = authEventsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create auth event time index: %w", err)
+	}
+
+	apiKeysCollection := GetCollection(DbName(), APIKeysCollection)
+
+	// Create unique index on API key prefix (key lookup)
+	_, err = apiKeysCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "prefix", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create API key prefix index: %w", err)
+	}
+
+	impersonationTokensCollection := GetCollection(DbName(), ImpersonationTokensCollection)
+
+	// Create indexes on both accounts so signing out either one finds the token
+	_, err = impersonationTokensCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create impersonation token indexes: %w", err)
+	}
+
+	// Create TTL index so entries go away once the token has expired
+	_, err = impersonationTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create impersonation token expiry index: %w", err)
+	}
+
 	return nil
 }
+
+// ensureSparsePhoneIndex creates the unique phone index as sparse. Databases
+// created before social login have a non-sparse index with the same name,
+// which is dropped first.
+func ensureSparsePhoneIndex(ctx context.Context, users *mongo.Collection) error {
+	cursor, err := users.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var specs []bson.M
+	if err := cursor.All(ctx, &specs); err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec["name"] == "phone_1" && spec["sparse"] != true {
+			if _, err := users.Indexes().DropOne(ctx, "phone_1"); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "phone", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	return err
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountExport is everything stored about a user
+type AccountExport struct {
+	ExportedAt     time.Time                   `json:"exportedAt"`
+	User           models.User                 `json:"user"`
+	Sessions       []models.Session            `json:"sessions"`
+	Passkeys       []models.WebAuthnCredential `json:"passkeys"`
+	AuthEvents     []models.AuthEvent          `json:"authEvents"`
+	SecurityEvents []models.SecurityEvent      `json:"securityEvents"`
+}
+
+// DeleteAccountRequest re-authenticates a deletion. Accounts without a
+// password send an empty body and must have logged in recently instead.
+type DeleteAccountRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"         example:"123456"`
+	RecoveryCode string `json:"recoveryCode" example:"ABCDE-12345"`
+}
+
+// AccountDeletionResponse tells when the account will be purged
+type AccountDeletionResponse struct {
+	Message     string    `json:"message"     example:"Account scheduled for deletion"`
+	ScheduledAt time.Time `json:"scheduledAt" example:"2025-01-15T12:00:00Z"`
+}
+
+// @Summary      Export account data
+// @Description  Download everything stored about the current user as JSON: account and profile, sessions, passkeys, and authentication and security events, including failed logins naming their email or phone and admin actions they took. Secrets such as password hashes are left out.
+// @Tags         account
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  AccountExport
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Not allowed while impersonating"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/account/export [get]
+func ExportAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	export := AccountExport{ExportedAt: time.Now()}
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&export.User); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	export.User.Roles = export.User.EffectiveRoles()
+
+	if export.Sessions, err = findByUser[models.Session](ctx, database.SessionsCollection, userID); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+	if export.Passkeys, err = findByUser[models.WebAuthnCredential](ctx, database.WebAuthnCredentialsCollection, userID); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+	// The same audit records the purge anonymises
+	audit := utils.AuditRecordsFilter(&export.User)
+	if export.AuthEvents, err = findAll[models.AuthEvent](ctx, database.AuthEventsCollection, audit); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+	if export.SecurityEvents, err = findAll[models.SecurityEvent](ctx, database.SecurityEventsCollection, audit); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to export account")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%s.json"`, userID.Hex()))
+	utils.ApiResponse(w, http.StatusOK, export)
+}
+
+// findByUser returns every document of the user in a collection, oldest first
+func findByUser[T any](ctx context.Context, collection string, userID primitive.ObjectID) ([]T, error) {
+	return findAll[T](ctx, collection, bson.M{"userId": userID})
+}
+
+// findAll returns every document matching filter in a collection, oldest first
+func findAll[T any](ctx context.Context, collection string, filter bson.M) ([]T, error) {
+	col := database.GetCollection(database.DbName(), collection)
+	cur, err := col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	result := []T{}
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// @Summary      Delete account
+// @Description  Schedule the current user's account for deletion and sign out everywhere. Requires the password (and a second factor if enabled); accounts without a password must have logged in within the last few minutes. Logging in again and cancelling at /api/v1/account/deletion/cancel keeps the account until the grace period ends; after that it and all related data are removed, and audit records are anonymised.
+// @Tags         account
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      DeleteAccountRequest  false  "Re-authentication"
+// @Success      202      {object}  AccountDeletionResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid password or code"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized or login too old"
+// @Failure      403      {object}  ErrorResponse  "Not allowed while impersonating"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      409      {object}  ErrorResponse  "Deletion already requested"
+// @Failure      429      {object}  ErrorResponse  "Too many failed attempts; see Retry-After"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/account [delete]
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	cfg := config.AppConfig
+	if cfg == nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Configuration not loaded")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.DeletionScheduledAt != nil {
+		utils.ApiError(w, http.StatusConflict, "Account deletion already requested")
+		return
+	}
+
+	// Wrong passwords and codes count towards the login lockout
+	if secondFactorLocked(ctx, w, &user) {
+		return
+	}
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			secondFactorFailed(ctx, w, r, &user, "Invalid password or code")
+			return
+		}
+	} else {
+		recent, err := recentlyLoggedIn(ctx, claims)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to check session")
+			return
+		}
+		if !recent {
+			utils.ApiError(w, http.StatusUnauthorized, "Please log in again to delete your account")
+			return
+		}
+	}
+	if user.MFAEnabled {
+		ok, err := verifySecondFactor(ctx, &user, req.Code, req.RecoveryCode)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+			return
+		}
+		if !ok {
+			secondFactorFailed(ctx, w, r, &user, "Invalid password or code")
+			return
+		}
+	}
+
+	now := time.Now()
+	scheduledAt := now.Add(cfg.Auth.AccountDeletionGrace)
+	res, err := users.UpdateOne(ctx,
+		bson.M{"_id": userID, "deletionScheduledAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"deletionRequestedAt": now,
+			"deletionScheduledAt": scheduledAt,
+			"updatedAt":           now,
+		}},
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to schedule account deletion")
+		return
+	}
+	if res.ModifiedCount == 0 {
+		utils.ApiError(w, http.StatusConflict, "Account deletion already requested")
+		return
+	}
+
+	_ = utils.RevokeAllSessions(ctx, userID, models.RevocationReasonAccountDeleted)
+	_ = utils.RevokeAccessToken(ctx, claims.ID, userID, claims.ExpiresAt.Time, models.RevocationReasonAccountDeleted)
+
+	event := utils.NewAuthEvent(r, models.AuthEventDeletionRequested, models.AuthOutcomeSuccess)
+	event.UserID = &userID
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Your account and all of its data will be deleted on %s.\n\n"+
+			"If you change your mind, log in before then and cancel the deletion.\n"+
+			"If you did not ask for this, log in, cancel the deletion and change your password.\n",
+			scheduledAt.UTC().Format("2 January 2006 at 15:04 UTC")),
+	})
+	if err != nil {
+		log.Printf("Failed to send account deletion email: %v", err)
+	}
+
+	utils.ClearAuthCookies(w)
+	utils.ApiResponse(w, http.StatusAccepted, AccountDeletionResponse{
+		Message:     "Account scheduled for deletion",
+		ScheduledAt: scheduledAt,
+	})
+}
+
+// recentlyLoggedIn reports whether the session behind the access token was
+// started within ReauthMaxAge. Refreshing does not count as logging in.
+func recentlyLoggedIn(ctx context.Context, claims *utils.AccessClaims) (bool, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return false, fmt.Errorf("configuration not loaded")
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+	var session models.Session
+	err = sessions.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(session.CreatedAt) <= cfg.Auth.ReauthMaxAge, nil
+}
+
+// @Summary      Cancel account deletion
+// @Description  Keep the current user's account after asking for it to be deleted. Only possible until the grace period is over.
+// @Tags         account
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "No deletion pending or grace period over"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Not allowed while impersonating"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/account/deletion/cancel [post]
+func CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx,
+		// Too late once the grace period is over or the purge has started
+		bson.M{
+			"_id":                 userID,
+			"deletionScheduledAt": bson.M{"$gt": time.Now()},
+			"purgingAt":           bson.M{"$exists": false},
+		},
+		bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"deletionRequestedAt": "", "deletionScheduledAt": ""},
+		},
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to cancel account deletion")
+		return
+	}
+	if res.MatchedCount == 0 {
+		utils.ApiError(w, http.StatusBadRequest, "No account deletion pending")
+		return
+	}
+
+	event := utils.NewAuthEvent(r, models.AuthEventDeletionCancelled, models.AuthOutcomeSuccess)
+	event.UserID = &userID
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "Account deletion cancelled"})
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultAdminPageSize = 20
+	maxAdminPageSize     = 100
+	// maxAdminPage keeps the number of skipped documents well in range
+	maxAdminPage = 1_000_000
+)
+
+// AdminUserListResponse is one page of users
+type AdminUserListResponse struct {
+	Users []models.User `json:"users"`
+	Total int64         `json:"total" example:"42"`
+	Page  int           `json:"page"  example:"1"`
+	Limit int           `json:"limit" example:"20"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" example:"Fraudulent applications"`
+}
+
+// @Summary      List users
+// @Description  Page through users, newest first. Email matches any part of the address, phone matches a prefix, country is case-insensitive. Dates are RFC 3339 or YYYY-MM-DD; createdTo is inclusive.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        email              query     string  false  "Email contains"
+// @Param        phone              query     string  false  "Phone starts with"
+// @Param        profileCompletion  query     bool    false  "Profile completed"
+// @Param        country            query     string  false  "Country"
+// @Param        suspended          query     bool    false  "Suspended"
+// @Param        createdFrom        query     string  false  "Created on or after"
+// @Param        createdTo          query     string  false  "Created on or before"
+// @Param        page               query     int     false  "Page number (1 to 1000000)"
+// @Param        limit              query     int     false  "Page size (max 100)"
+// @Success      200  {object}  AdminUserListResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid filter"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users [get]
+func AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := bson.M{}
+
+	if v := strings.TrimSpace(q.Get("email")); v != "" {
+		filter["email"] = primitive.Regex{Pattern: regexp.QuoteMeta(strings.ToLower(v))}
+	}
+	if v := strings.TrimSpace(q.Get("phone")); v != "" {
+		filter["phone"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(v)}
+	}
+	if v := strings.TrimSpace(q.Get("country")); v != "" {
+		filter["country"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(v) + "$", Options: "i"}
+	}
+	for _, name := range []string{"profileCompletion", "suspended"} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid "+name)
+			return
+		}
+		if b {
+			filter[name] = true
+		} else {
+			// Older documents may not have the field at all
+			filter[name] = bson.M{"$ne": true}
+		}
+	}
+
+	created := bson.M{}
+	if v := q.Get("createdFrom"); v != "" {
+		from, _, err := parseAdminDate(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid createdFrom")
+			return
+		}
+		created["$gte"] = from
+	}
+	if v := q.Get("createdTo"); v != "" {
+		to, dateOnly, err := parseAdminDate(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid createdTo")
+			return
+		}
+		if dateOnly {
+			created["$lt"] = to.AddDate(0, 0, 1)
+		} else {
+			created["$lte"] = to
+		}
+	}
+	if len(created) > 0 {
+		filter["createdAt"] = created
+	}
+
+	page, err := queryInt(q.Get("page"), 1)
+	if err != nil || page < 1 || page > maxAdminPage {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid page")
+		return
+	}
+	limit, err := queryInt(q.Get("limit"), defaultAdminPageSize)
+	if err != nil || limit < 1 {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	total, err := users.CountDocuments(ctx, filter)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+
+	cur, err := users.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+	result := []models.User{}
+	if err := cur.All(ctx, &result); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+	for i := range result {
+		result[i].Roles = result[i].EffectiveRoles()
+	}
+
+	utils.ApiResponse(w, http.StatusOK, AdminUserListResponse{
+		Users: result,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// @Summary      Get user
+// @Description  Return any user by id.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  models.User
+// @Failure      400  {object}  ErrorResponse  "Invalid user id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Router       /api/v1/admin/users/{id} [get]
+func AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	user.Roles = user.EffectiveRoles()
+
+	utils.ApiResponse(w, http.StatusOK, user)
+}
+
+// @Summary      Suspend user
+// @Description  Block the user from logging in and end all of their sessions. Their access tokens stop working at once on this instance; other instances may accept them for up to REVOCATION_CACHE_TTL (10 seconds by default) while their cached lookups expire. Recorded in the audit log.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string              true   "User ID"
+// @Param        payload  body      SuspendUserRequest  false  "Reason shown to staff"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid user id or own account"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  ErrorResponse  "Forbidden"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users/{id}/suspend [post]
+func AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+	if claims, ok := middleware.GetClaims(r.Context()); ok && claims.Subject == userID.Hex() {
+		utils.ApiError(w, http.StatusBadRequest, "You cannot suspend your own account")
+		return
+	}
+
+	// The body is optional
+	var req SuspendUserRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	err = utils.SetUserSuspended(ctx, userID, true, strings.TrimSpace(req.Reason))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to suspend user")
+		return
+	}
+
+	recordAdminAction(ctx, r, models.AuthEventAdminSuspend, userID, strings.TrimSpace(req.Reason))
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "User suspended"})
+}
+
+// @Summary      Unsuspend user
+// @Description  Allow a suspended user to log in again. Recorded in the audit log.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid user id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users/{id}/unsuspend [post]
+func AdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = utils.SetUserSuspended(ctx, userID, false, "")
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to unsuspend user")
+		return
+	}
+
+	recordAdminAction(ctx, r, models.AuthEventAdminUnsuspend, userID, "")
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "User unsuspended"})
+}
+
+// @Summary      Force logout
+// @Description  End every session of the user and revoke their access tokens. Recorded in the audit log.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid user id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users/{id}/logout [post]
+func AdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	if n, err := users.CountDocuments(ctx, bson.M{"_id": userID}); err != nil || n == 0 {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := utils.RevokeAllSessions(ctx, userID, models.RevocationReasonAdmin); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	recordAdminAction(ctx, r, models.AuthEventAdminLogout, userID, "")
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "User logged out everywhere"})
+}
+
+// @Summary      Reset two-factor authentication
+// @Description  Turn off the user's authenticator app and delete their recovery codes, e.g. after they lost their phone. Passkeys are kept. Recorded in the audit log.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid user id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users/{id}/mfa/reset [post]
+func AdminResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"mfaEnabled": false,
+			"updatedAt":  time.Now(),
+		},
+		"$unset": bson.M{
+			"totpSecret":         "",
+			"totpPendingSecret":  "",
+			"totpLastUsedStep":   "",
+			"recoveryCodeHashes": "",
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to reset two-factor authentication")
+		return
+	}
+	if res.MatchedCount == 0 {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	recordAdminAction(ctx, r, models.AuthEventAdminResetMFA, userID, "")
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "Two-factor authentication reset"})
+}
+
+// recordAdminAction writes an audit log entry naming the admin who acted on
+// the user
+func recordAdminAction(ctx context.Context, r *http.Request, eventType string, userID primitive.ObjectID, reason string) {
+	event := utils.NewAuthEvent(r, eventType, models.AuthOutcomeSuccess)
+	event.UserID = &userID
+	event.Reason = reason
+	if claims, ok := middleware.GetClaims(r.Context()); ok {
+		if adminID, err := claims.UserID(); err == nil {
+			event.ActorID = &adminID
+		}
+	}
+	_ = utils.RecordAuthEvent(ctx, event)
+}
+
+// parseAdminDate accepts RFC 3339 timestamps and plain dates (UTC) and
+// reports which one it got
+func parseAdminDate(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+func queryInt(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
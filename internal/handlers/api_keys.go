@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"      example:"Partner agency sync"`
+	Scopes    []string   `json:"scopes"    example:"users:read"`
+	ExpiresAt *time.Time `json:"expiresAt" example:"2027-01-01T00:00:00Z"`
+}
+
+// APIKeyCreatedResponse carries the new key. The key is only ever shown here.
+type APIKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"key" example:"uak_3f9a1c2b7d4e_Zx8..."`
+}
+
+// @Summary      Create API key
+// @Description  Create a key for a partner or batch job. Scopes are permissions (e.g. users:read). Send it as X-API-Key or as a Bearer token. The key is returned once and cannot be retrieved later.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      CreateAPIKeyRequest  true  "Key details"
+// @Success      201      {object}  APIKeyCreatedResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  ErrorResponse  "Forbidden"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/api-keys [post]
+func AdminCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	adminID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		utils.ApiError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidPermission(scope) {
+			utils.ApiError(w, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		utils.ApiError(w, http.StatusBadRequest, "expiresAt must be in the future")
+		return
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate API key")
+		return
+	}
+
+	apiKey := models.APIKey{
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.SHA256Hex(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		CreatedBy: adminID,
+		CreatedAt: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	keys := database.GetCollection(database.DbName(), database.APIKeysCollection)
+	res, err := keys.InsertOne(ctx, apiKey)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	apiKey.ID = res.InsertedID.(primitive.ObjectID)
+
+	utils.ApiResponse(w, http.StatusCreated, APIKeyCreatedResponse{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+// @Summary      List API keys
+// @Description  List all API keys, newest first, including revoked and expired ones. Keys themselves are never shown.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.APIKey
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/api-keys [get]
+func AdminListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	keys := database.GetCollection(database.DbName(), database.APIKeysCollection)
+	cur, err := keys.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	result := []models.APIKey{}
+	if err := cur.All(ctx, &result); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, result)
+}
+
+// @Summary      Revoke API key
+// @Description  Stop an API key from working. Revoked keys stay listed.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "API key ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid key id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "API key not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/api-keys/{id} [delete]
+func AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	adminID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	keyID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid key id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	keys := database.GetCollection(database.DbName(), database.APIKeysCollection)
+	res, err := keys.UpdateOne(ctx,
+		bson.M{"_id": keyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedBy": adminID}},
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	if res.MatchedCount == 0 {
+		// Revoking twice is fine; an unknown id is not
+		n, err := keys.CountDocuments(ctx, bson.M{"_id": keyID})
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke API key")
+			return
+		}
+		if n == 0 {
+			utils.ApiError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "API key revoked"})
+}
@@ -2,22 +2,32 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
-	"github.com/MH-PAVEL/uni-backend-go/internal/config"
 	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
 	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
 	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/password"
 	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+var phoneRegex = regexp.MustCompile(`^[0-9]{11}$`)
+
+// dummyPasswordHash stands in for the password hash when no account
+// matches a login, so unknown identifiers take as long to reject
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
 
 type SignupRequest struct {
 	Email    string `json:"email" example:"F2HbU@example.com"`
@@ -36,6 +46,20 @@ type AuthResponse struct {
 	User         interface{} `json:"user,omitempty"`
 }
 
+// userSummary is the user object returned alongside freshly issued tokens
+func userSummary(user *models.User) bson.M {
+	return bson.M{
+		"id":                user.ID.Hex(),
+		"email":             user.Email,
+		"phone":             user.Phone,
+		"emailVerified":     user.EmailVerified,
+		"phoneVerified":     user.PhoneVerified,
+		"profileCompletion": user.ProfileCompletion,
+		"roles":             user.EffectiveRoles(),
+		"createdAt":         user.CreatedAt,
+	}
+}
+
 // LogoutResponse documents a simple message payload
 type LogoutResponse struct {
     Message string `json:"message" example:"logged out"`
@@ -50,14 +74,13 @@ type ErrorResponse struct {
 
 
 // @Summary      Signup
-// @Description  Create a user and return access & refresh tokens (also set as cookies).
+// @Description  Create an account. The response is the same whether or not the email or phone is already registered: a new account gets a verification link, an existing one gets a notice by email. Log in once the account exists.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        payload  body      SignupRequest  true  "Signup payload"
-// @Success      200      {object}  AuthResponse
-// @Failure      400      {object}  ErrorResponse  "Invalid request"
-// @Failure      409      {object}  ErrorResponse  "User already exists"
+// @Success      202      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request or password rejected by policy"
 // @Failure      500      {object}  ErrorResponse  "Internal error"
 // @Router       /api/v1/auth/signup [post]
 func Signup(w http.ResponseWriter, r *http.Request) {
@@ -78,83 +101,129 @@ func Signup(w http.ResponseWriter, r *http.Request) {
 		utils.ApiError(w, http.StatusBadRequest, "Invalid email format")
 		return
 	}
-	if len(req.Phone) != 11 {
+	if !phoneRegex.MatchString(req.Phone) {
 		utils.ApiError(w, http.StatusBadRequest, "Phone number must be 11 digits")
 		return
 	}
-	if len(req.Password) == 0 {
-		utils.ApiError(w, http.StatusBadRequest, "Missing password")
+	if err := password.Validate(req.Password, req.Email, req.Phone); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	users := database.GetCollection(database.DbName(), database.UsersCollection)
-
-	// Uniqueness check
-	filter := bson.M{"$or": []bson.M{{"email": req.Email}, {"phone": req.Phone}}}
-	var ex bson.M
-	if err := users.FindOne(ctx, filter).Decode(&ex); err == nil {
-		utils.ApiError(w, http.StatusBadRequest, "User already exists")
-		return
-	}
-
-	// Hash password
+	// Hash before the insert so a taken email or phone costs as much as a
+	// new account
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		utils.ApiError(w, http.StatusInternalServerError, "Could not hash password")
 		return
 	}
 
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
 	now := time.Now()
-	doc := bson.M{
-		"email":             req.Email,
-		"phone":             req.Phone,
-		"password":          string(hash),
-		"profileCompletion": false,
-		"createdAt":         now,
-		"updatedAt":         now,
-	}
-	res, err := users.InsertOne(ctx, doc)
-	if err != nil {
+	user := models.User{
+		Email:     req.Email,
+		Phone:     req.Phone,
+		Password:  string(hash),
+		Roles:     []string{models.RoleStudent},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	res, err := users.InsertOne(ctx, user)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
 		utils.ApiError(w, http.StatusInternalServerError, "Could not create user")
 		return
 	}
-	uid := res.InsertedID.(primitive.ObjectID)
 
-	access, refresh, err := utils.IssueTokens(ctx, uid)
+	// Both outcomes queue one email and give the same answer, so neither
+	// the response nor its timing reveals whether the email or phone is
+	// already registered. The email is dropped when the queue is busy.
+	var job func()
 	if err != nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate authentication tokens")
-		return
-	}
+		event := utils.NewAuthEvent(r, models.AuthEventSignup, models.AuthOutcomeFailure)
+		event.Reason = "already_registered"
+		event.Identifier = req.Email
+		_ = utils.RecordAuthEvent(ctx, event)
 
-	// set cookies
-	utils.SetAccessCookie(w, access)
-	utils.SetRefreshCookie(w, refresh)
+		job = func() { notifyExistingAccounts(req) }
+	} else {
+		user.ID = res.InsertedID.(primitive.ObjectID)
 
-	utils.ApiResponse(w, http.StatusOK, AuthResponse{
-		Token:        access,
-		RefreshToken: refresh,
-		User: bson.M{
-			"id":                uid.Hex(),
-			"email":             req.Email,
-			"phone":             req.Phone,
-			"profileCompletion": false,
-			"createdAt":         now,
-		},
+		event := utils.NewAuthEvent(r, models.AuthEventSignup, models.AuthOutcomeSuccess)
+		event.UserID = &user.ID
+		_ = utils.RecordAuthEvent(ctx, event)
+
+		// The account is usable right away; verification unlocks protected steps
+		job = func() { sendSignupVerificationEmail(user.ID, req.Email) }
+	}
+	if !signupQueue.tryStart(job) {
+		log.Printf("Signup queue full, signup email dropped")
+	}
+
+	utils.ApiResponse(w, http.StatusAccepted, MessageResponse{
+		Message: "Check your email to continue",
 	})
 }
 
+// sendSignupVerificationEmail emails a new account its verification link
+func sendSignupVerificationEmail(userID primitive.ObjectID, email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := sendVerificationEmail(ctx, userID, email); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+}
+
+// notifyExistingAccounts tells the owners of the accounts holding the
+// email or phone from a signup attempt that someone tried to register it
+func notifyExistingAccounts(req SignupRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	filter := bson.M{"$or": []bson.M{{"email": req.Email}, {"phone": req.Phone}}}
+
+	cur, err := users.Find(ctx, filter, options.Find().SetProjection(bson.M{"email": 1}))
+	if err != nil {
+		log.Printf("Failed to look up existing accounts: %v", err)
+		return
+	}
+	var existing []models.User
+	if err := cur.All(ctx, &existing); err != nil {
+		log.Printf("Failed to look up existing accounts: %v", err)
+		return
+	}
+
+	for _, user := range existing {
+		err := mailer.Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "You already have an account",
+			Body: "Someone tried to sign up with your email address or phone number, which are already registered.\n\n" +
+				"If this was you, log in instead, or reset your password if you have forgotten it.\n" +
+				"If it was not you, you can ignore this email.\n",
+		})
+		if err != nil {
+			log.Printf("Failed to send existing account email: %v", err)
+		}
+	}
+}
+
 // @Summary      Login
-// @Description  Login with email or phone and get access & refresh tokens (also set as cookies).
+// @Description  Login with email or phone and get access & refresh tokens (also set as cookies). Repeated failures for the same identifier lock it temporarily, for longer each time. If two-factor authentication is enabled, an MFA challenge token is returned instead; complete it at /api/v1/auth/login/mfa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        payload  body      LoginRequest  true  "Login payload"
 // @Success      200      {object}  AuthResponse
+// @Success      202      {object}  MFAChallengeResponse  "Two-factor authentication required"
 // @Failure      400      {object}  ErrorResponse  "Invalid request"
 // @Failure      401      {object}  ErrorResponse  "Invalid credentials"
+// @Failure      403      {object}  ErrorResponse  "Account suspended"
+// @Failure      429      {object}  ErrorResponse  "Too many failed attempts; see Retry-After"
 // @Failure      500      {object}  ErrorResponse  "Internal error"
 // @Router       /api/v1/auth/login [post]
 func Login(w http.ResponseWriter, r *http.Request) {
@@ -181,47 +250,48 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	var user bson.M
-	if err := col.FindOne(ctx, filter).Decode(&user); err != nil {
-		utils.ApiError(w, http.StatusUnauthorized, "User not found")
+	// Locked identifiers are rejected before the password is looked at
+	if loginLocked(ctx, w, r, req.Identifier, models.AuthMethodPassword) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user["password"].(string)), []byte(req.Password)); err != nil {
-		utils.ApiError(w, http.StatusUnauthorized, "Invalid credentials")
+	// Unknown identifiers and accounts without a password are checked
+	// against a dummy hash, so every failure looks and takes the same
+	var user models.User
+	err := col.FindOne(ctx, filter).Decode(&user)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to log in")
 		return
 	}
+	found := err == nil && user.Password != ""
 
-	uid := user["_id"].(primitive.ObjectID)
-
-	access, refresh, err := utils.IssueTokens(ctx, uid)
-	if err != nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate authentication tokens")
+	hash := dummyPasswordHash
+	if found {
+		hash = []byte(user.Password)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil || !found {
+		var owner *models.User
+		if found {
+			owner = &user
+		}
+		loginFailed(ctx, w, r, req.Identifier, owner)
 		return
 	}
-	utils.SetAccessCookie(w, access)
-	utils.SetRefreshCookie(w, refresh)
 
-	utils.ApiResponse(w, http.StatusOK, AuthResponse{
-		Token:        access,
-		RefreshToken: refresh,
-		User: bson.M{
-			"id":                uid.Hex(),
-			"email":             user["email"],
-			"phone":             user["phone"],
-			"profileCompletion": user["profileCompletion"],
-			"createdAt":         user["createdAt"],
-		},
-	})
+	// Failures are only cleared once the second factor is done too, in
+	// issueLoginTokens
+	finishLogin(ctx, w, r, &user, models.AuthMethodPassword)
 }
 
 // @Summary      Refresh access token
-// @Description  Get a new access token using a valid refresh token
+// @Description  Get a new access token using a valid refresh token. The refresh token is rotated; presenting an already rotated token revokes its session. When the refresh token comes from the cookie, the X-CSRF-Token header must match the csrf_token cookie.
 // @Tags         auth
 // @Produce      json
+// @Param        X-CSRF-Token  header    string  false  "CSRF token (cookie auth)"
 // @Success      200  {object}  AuthResponse
 // @Failure      400  {object}  ErrorResponse  "Missing refresh token"
 // @Failure      401  {object}  ErrorResponse  "Invalid or expired refresh token"
+// @Failure      403  {object}  ErrorResponse  "Account suspended or invalid CSRF token"
 // @Failure      500  {object}  ErrorResponse  "Internal error"
 // @Router       /api/v1/auth/refresh [post]
 func Refresh(w http.ResponseWriter, r *http.Request) {
@@ -230,67 +300,68 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	token := utils.GetRefreshTokenFromReq(r)
+	token, fromCookie := utils.ReadRefreshToken(r)
 	if token == "" {
 		utils.ApiError(w, http.StatusBadRequest, "Missing refresh token")
 		return
 	}
+	if fromCookie && !utils.ValidCSRF(r) {
+		utils.ApiError(w, http.StatusForbidden, "Invalid CSRF token")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	// Find user by refresh token hash
-	users := database.GetCollection(database.DbName(), database.UsersCollection)
-	tokenHash := utils.SHA256Hex(token)
-	
-	var user models.User
-	err := users.FindOne(ctx, bson.M{
-		"refreshTokenHash": tokenHash,
-		"refreshTokenExpires": bson.M{"$gt": time.Now()},
-	}).Decode(&user)
-	
-	if err != nil {
-		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+	// Rotate the refresh token of the session that owns it
+	session, newRefresh, err := utils.RotateSession(ctx, r, token)
+	if errors.Is(err, utils.ErrRefreshTokenReused) {
+		event := utils.NewAuthEvent(r, models.AuthEventRefresh, models.AuthOutcomeFailure)
+		event.Reason = "token_reuse"
+		event.UserID = &session.UserID
+		event.SessionID = &session.ID
+		_ = utils.RecordAuthEvent(ctx, event)
+
+		// The whole session has been revoked; make the client log in again
+		utils.ClearAuthCookies(w)
+		utils.ApiError(w, http.StatusUnauthorized, "Refresh token reuse detected, please log in again")
 		return
 	}
+	if errors.Is(err, utils.ErrSessionNotFound) {
+		event := utils.NewAuthEvent(r, models.AuthEventRefresh, models.AuthOutcomeFailure)
+		event.Reason = "invalid_token"
+		_ = utils.RecordAuthEvent(ctx, event)
 
-	// Generate new access token
-	cfg := config.AppConfig
-	if cfg == nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Configuration not loaded")
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
 		return
 	}
-
-	access, err := utils.GenerateJWT(cfg.Auth.JWTSecret, user.ID.Hex(), cfg.Auth.AccessTTL)
 	if err != nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate access token")
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to update refresh token")
 		return
 	}
 
-	// Generate new refresh token
-	newRefresh, err := utils.GenerateSecureToken(32)
-	if err != nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate refresh token")
+	event := utils.NewAuthEvent(r, models.AuthEventRefresh, models.AuthOutcomeSuccess)
+	event.UserID = &session.UserID
+	event.SessionID = &session.ID
+
+	access, err := utils.GenerateAccessToken(ctx, session.UserID, session.ID)
+	if errors.Is(err, utils.ErrAccountSuspended) {
+		event.Outcome = models.AuthOutcomeFailure
+		event.Reason = "suspended"
+		_ = utils.RecordAuthEvent(ctx, event)
+
+		_ = utils.RevokeSession(ctx, session.UserID, session.ID)
+		utils.ClearAuthCookies(w)
+		utils.ApiError(w, http.StatusForbidden, "Account suspended")
 		return
 	}
-
-	// Update user with new refresh token
-	newHash := utils.SHA256Hex(newRefresh)
-	expiresAt := time.Now().Add(cfg.Auth.RefreshTTL)
-	
-	_, err = users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
-		"$set": bson.M{
-			"refreshTokenHash": newHash,
-			"refreshTokenExpires": expiresAt,
-			"updatedAt": time.Now(),
-		},
-	})
-	
 	if err != nil {
-		utils.ApiError(w, http.StatusInternalServerError, "Failed to update refresh token")
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate access token")
 		return
 	}
 
+	_ = utils.RecordAuthEvent(ctx, event)
+
 	utils.SetAccessCookie(w, access)
 	utils.SetRefreshCookie(w, newRefresh)
 
@@ -301,11 +372,13 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary      Logout
-// @Description  Revoke current refresh token and clear auth cookies
+// @Description  End the session owning the refresh token, revoke its access tokens and clear auth cookies. When the refresh token comes from the cookie, the X-CSRF-Token header must match the csrf_token cookie.
 // @Tags         auth
 // @Produce      json
+// @Param        X-CSRF-Token  header    string  false  "CSRF token (cookie auth)"
 // @Success      200  {object}  LogoutResponse
 // @Failure      400  {object}  ErrorResponse  "Missing refresh token"
+// @Failure      403  {object}  ErrorResponse  "Invalid CSRF token"
 // @Router       /api/v1/auth/logout [post]
 func Logout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -313,47 +386,38 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	token := utils.GetRefreshTokenFromReq(r)
+	token, fromCookie := utils.ReadRefreshToken(r)
 	if token == "" {
 		utils.ApiError(w, http.StatusBadRequest, "Missing refresh token")
 		return
 	}
+	if fromCookie && !utils.ValidCSRF(r) {
+		utils.ApiError(w, http.StatusForbidden, "Invalid CSRF token")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	// Find and revoke refresh token
-	users := database.GetCollection(database.DbName(), database.UsersCollection)
-	tokenHash := utils.SHA256Hex(token)
-	
-	_, _ = users.UpdateOne(ctx, bson.M{
-		"refreshTokenHash": tokenHash,
-	}, bson.M{
-		"$unset": bson.M{
-			"refreshTokenHash": "",
-			"refreshTokenExpires": "",
-		},
-		"$set": bson.M{
-			"updatedAt": time.Now(),
-		},
-	})
+	// Revoke the session that owns the refresh token
+	session, err := utils.RevokeSessionByRefreshToken(ctx, token)
+	if err == nil {
+		event := utils.NewAuthEvent(r, models.AuthEventLogout, models.AuthOutcomeSuccess)
+		event.UserID = &session.UserID
+		event.SessionID = &session.ID
+		_ = utils.RecordAuthEvent(ctx, event)
+	}
 
-	// Clear cookies regardless of success
-	http.SetCookie(w, &http.Cookie{
-		Name:     utils.RefreshTokenCookieName,
-		Value:    "",
-		Path:     "/api/v1/auth",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	// and the access token presented with it, in case it was not recorded
+	// on the session
+	if claims, err := utils.ParseAccessToken(utils.GetAccessTokenFromReq(r)); err == nil {
+		if userID, err := claims.UserID(); err == nil {
+			_ = utils.RevokeAccessToken(ctx, claims.ID, userID, claims.ExpiresAt.Time, models.RevocationReasonLogout)
+		}
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     utils.AccessTokenCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	// Clear cookies regardless of success
+	utils.ClearAuthCookies(w)
 
 	utils.ApiResponse(w, http.StatusOK, LogoutResponse{
 		Message: "Logout successful",
@@ -374,14 +438,13 @@ func GetMe(w http.ResponseWriter, r *http.Request) {
 		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
-	uid := r.Context().Value(middleware.CtxUserID)
-	if uid == nil {
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
 		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
 		return
 	}
 
-	// Convert userID string -> ObjectID
-	userID, err := primitive.ObjectIDFromHex(uid.(string))
+	userID, err := claims.UserID()
 	if err != nil {
 		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
 		return
@@ -398,6 +461,7 @@ func GetMe(w http.ResponseWriter, r *http.Request) {
 		utils.ApiError(w, http.StatusNotFound, "User not found")
 		return
 	}
+	user.Roles = user.EffectiveRoles()
 
 	utils.ApiResponse(w, http.StatusOK, user)
 }
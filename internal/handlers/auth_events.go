@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultActivityLimit is how many events the recent activity endpoint returns by default
+const defaultActivityLimit = 20
+
+// AuthEventListResponse is one page of audit log entries
+type AuthEventListResponse struct {
+	Events []models.AuthEvent `json:"events"`
+	Total  int64              `json:"total" example:"42"`
+	Page   int                `json:"page"  example:"1"`
+	Limit  int                `json:"limit" example:"20"`
+}
+
+// @Summary      List authentication events
+// @Description  Page through the authentication audit log, newest first. Dates are RFC 3339 or YYYY-MM-DD; to is inclusive.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        userId   query     string  false  "User ID"
+// @Param        type     query     string  false  "Event type (signup, login, refresh, logout, token_rejected, admin_suspend, ...)"
+// @Param        outcome  query     string  false  "Outcome (success, failure)"
+// @Param        ip       query     string  false  "Client IP"
+// @Param        from     query     string  false  "On or after"
+// @Param        to       query     string  false  "On or before"
+// @Param        page     query     int     false  "Page number (1 to 1000000)"
+// @Param        limit    query     int     false  "Page size (max 100)"
+// @Success      200  {object}  AuthEventListResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid filter"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/auth-events [get]
+func AdminListAuthEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := bson.M{}
+
+	if v := q.Get("userId"); v != "" {
+		userID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid userId")
+			return
+		}
+		filter["userId"] = userID
+	}
+	for _, name := range []string{"type", "outcome", "ip"} {
+		if v := strings.TrimSpace(q.Get(name)); v != "" {
+			filter[name] = v
+		}
+	}
+
+	created := bson.M{}
+	if v := q.Get("from"); v != "" {
+		from, _, err := parseAdminDate(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		created["$gte"] = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, dateOnly, err := parseAdminDate(v)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		if dateOnly {
+			created["$lt"] = to.AddDate(0, 0, 1)
+		} else {
+			created["$lte"] = to
+		}
+	}
+	if len(created) > 0 {
+		filter["createdAt"] = created
+	}
+
+	page, err := queryInt(q.Get("page"), 1)
+	if err != nil || page < 1 || page > maxAdminPage {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid page")
+		return
+	}
+	limit, err := queryInt(q.Get("limit"), defaultAdminPageSize)
+	if err != nil || limit < 1 {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	events := database.GetCollection(database.DbName(), database.AuthEventsCollection)
+
+	total, err := events.CountDocuments(ctx, filter)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list events")
+		return
+	}
+
+	cur, err := events.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list events")
+		return
+	}
+
+	result := []models.AuthEvent{}
+	if err := cur.All(ctx, &result); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to list events")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, AuthEventListResponse{
+		Events: result,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	})
+}
+
+// @Summary      Recent account activity
+// @Description  List the most recent sign-ins, refreshes, logouts and rejected attempts on the current user's account, newest first.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit  query     int  false  "Number of events (max 100)"
+// @Success      200    {array}   models.AuthEvent
+// @Failure      400    {object}  ErrorResponse  "Invalid limit"
+// @Failure      401    {object}  ErrorResponse  "Unauthorized"
+// @Failure      500    {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/activity [get]
+func ListMyAuthEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	limit, err := queryInt(r.URL.Query().Get("limit"), defaultActivityLimit)
+	if err != nil || limit < 1 {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	events := database.GetCollection(database.DbName(), database.AuthEventsCollection)
+	cur, err := events.Find(ctx, bson.M{"userId": userID}, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load activity")
+		return
+	}
+
+	result := []models.AuthEvent{}
+	if err := cur.All(ctx, &result); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load activity")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, result)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/testutil"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// serveJSON runs handler on a request with body encoded as JSON
+func serveJSON(t *testing.T, handler http.HandlerFunc, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, target, bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestRefresh(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+
+	user := insertTestUser(t, models.User{Email: "refresh@example.com", EmailVerified: true}, "")
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+	_, refresh, err := utils.IssueTokens(ctx, r, user.ID)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	w := serveJSON(t, Refresh, http.MethodPost, "/api/v1/auth/refresh", utils.RefreshRequest{RefreshToken: refresh})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var pair AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &pair); err != nil {
+		t.Fatal(err)
+	}
+	if pair.Token == "" || pair.RefreshToken == "" || pair.RefreshToken == refresh {
+		t.Fatalf("expected a new token pair, got %+v", pair)
+	}
+
+	// Replaying the rotated token ends the session
+	w = serveJSON(t, Refresh, http.MethodPost, "/api/v1/auth/refresh", utils.RefreshRequest{RefreshToken: refresh})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("replay status = %d, body %s", w.Code, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["error"] != "Refresh token reuse detected, please log in again" {
+		t.Fatalf("replay error = %q", body["error"])
+	}
+	reuse := bson.M{
+		"type":      models.AuthEventRefresh,
+		"reason":    "token_reuse",
+		"userId":    user.ID,
+		"sessionId": bson.M{"$exists": true},
+	}
+	if n := countTestDocuments(t, database.AuthEventsCollection, reuse); n != 1 {
+		t.Fatalf("%d reuse events with user and session ids, want 1", n)
+	}
+
+	w = serveJSON(t, Refresh, http.MethodPost, "/api/v1/auth/refresh", utils.RefreshRequest{RefreshToken: pair.RefreshToken})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse: status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+// assertSameResponse fails unless both responses have the same status and
+// byte-identical bodies
+func assertSameResponse(t *testing.T, what string, a, b *httptest.ResponseRecorder) {
+	t.Helper()
+	if a.Code != b.Code || !bytes.Equal(a.Body.Bytes(), b.Body.Bytes()) {
+		t.Fatalf("%s: responses differ:\n%d %s\n%d %s", what, a.Code, a.Body, b.Code, b.Body)
+	}
+	if a.Header().Get("Retry-After") == "" != (b.Header().Get("Retry-After") == "") {
+		t.Fatalf("%s: only one response has Retry-After", what)
+	}
+	if len(a.Result().Cookies()) != 0 || len(b.Result().Cookies()) != 0 {
+		t.Fatalf("%s: failed attempt set cookies", what)
+	}
+}
+
+func TestLoginDoesNotRevealAccounts(t *testing.T) {
+	cfg := testutil.Mongo(t)
+
+	insertTestUser(t, models.User{Email: "known@example.com", Phone: "01711111111", EmailVerified: true}, "correct horse battery")
+	insertTestUser(t, models.User{Email: "social@example.com", EmailVerified: true}, "")
+
+	login := func(identifier string) *httptest.ResponseRecorder {
+		return serveJSON(t, Login, http.MethodPost, "/api/v1/auth/login",
+			LoginRequest{Identifier: identifier, Password: "wrong password"})
+	}
+
+	unknown := login("nobody@example.com")
+	if unknown.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown identifier: status = %d, body %s", unknown.Code, unknown.Body)
+	}
+	assertSameResponse(t, "known email", unknown, login("known@example.com"))
+	assertSameResponse(t, "known phone", unknown, login("01711111111"))
+	assertSameResponse(t, "account without a password", unknown, login("social@example.com"))
+
+	// Locking out behaves the same too
+	var lockedUnknown, lockedKnown *httptest.ResponseRecorder
+	for range cfg.Auth.LoginMaxFailures {
+		lockedUnknown = login("nobody@example.com")
+		lockedKnown = login("known@example.com")
+	}
+	if lockedUnknown.Code != http.StatusTooManyRequests {
+		t.Fatalf("after %d more failures: status = %d, body %s", cfg.Auth.LoginMaxFailures, lockedUnknown.Code, lockedUnknown.Body)
+	}
+	assertSameResponse(t, "locked", lockedUnknown, lockedKnown)
+}
+
+func TestSignupDoesNotRevealAccounts(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+
+	insertTestUser(t, models.User{Email: "taken@example.com", Phone: "01722222222"}, "correct horse battery")
+
+	signup := func(email, phone string) *httptest.ResponseRecorder {
+		return serveJSON(t, Signup, http.MethodPost, "/api/v1/auth/signup",
+			SignupRequest{Email: email, Phone: phone, Password: "Lantern-Meadow-42"})
+	}
+
+	fresh := signup("fresh@example.com", "01733333333")
+	if fresh.Code != http.StatusAccepted {
+		t.Fatalf("new account: status = %d, body %s", fresh.Code, fresh.Body)
+	}
+	assertSameResponse(t, "existing email", fresh, signup("taken@example.com", "01744444444"))
+	assertSameResponse(t, "existing phone", fresh, signup("other@example.com", "01722222222"))
+
+	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := WaitForBackgroundJobs(waitCtx); err != nil {
+		t.Fatalf("WaitForBackgroundJobs: %v", err)
+	}
+
+	for email, want := range map[string]int64{
+		"fresh@example.com": 1,
+		"taken@example.com": 1,
+		"other@example.com": 0,
+	} {
+		if n := countTestDocuments(t, database.UsersCollection, bson.M{"email": email}); n != want {
+			t.Fatalf("%d accounts for %s, want %d", n, email, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+)
+
+// backgroundQueueWait is how long a request waits for a free background
+// slot before it is turned away
+const backgroundQueueWait = 2 * time.Second
+
+// backgroundQueue runs work that must not delay the response, such as
+// sending email, with at most a fixed number of jobs in flight
+type backgroundQueue struct {
+	slots   chan struct{}
+	pending sync.WaitGroup
+}
+
+func newBackgroundQueue(size int) *backgroundQueue {
+	return &backgroundQueue{slots: make(chan struct{}, size)}
+}
+
+var (
+	// signupQueue sends verification emails to new accounts, and tells
+	// account owners that someone tried to sign up with their email or phone
+	signupQueue = newBackgroundQueue(16)
+	// passwordResetQueue sends password reset emails
+	passwordResetQueue = newBackgroundQueue(16)
+	// passwordlessQueue sends passwordless login codes and links; SMS cost
+	// money, so it is kept small
+	passwordlessQueue = newBackgroundQueue(8)
+	// lockoutQueue records lockouts and notifies the account owners
+	lockoutQueue = newBackgroundQueue(8)
+
+	backgroundQueues = []*backgroundQueue{signupQueue, passwordResetQueue, passwordlessQueue, lockoutQueue}
+)
+
+// start runs job in the background once a slot is free. If none frees up
+// within backgroundQueueWait it answers 503 and returns false.
+func (q *backgroundQueue) start(w http.ResponseWriter, r *http.Request, job func()) bool {
+	timer := time.NewTimer(backgroundQueueWait)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+	case <-timer.C:
+		w.Header().Set("Retry-After", "5")
+		utils.ApiError(w, http.StatusServiceUnavailable, "Too many requests right now, please try again shortly")
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+
+	q.run(job)
+	return true
+}
+
+// tryStart runs job in the background if a slot is free right away, and
+// drops it otherwise. It is for work whose absence must not change the
+// response, and returns whether job was started.
+func (q *backgroundQueue) tryStart(job func()) bool {
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return false
+	}
+	q.run(job)
+	return true
+}
+
+// run runs job in a goroutine holding an already taken slot
+func (q *backgroundQueue) run(job func()) {
+	q.pending.Add(1)
+	go func() {
+		defer func() {
+			<-q.slots
+			q.pending.Done()
+		}()
+		job()
+	}()
+}
+
+// WaitForBackgroundJobs blocks until the emails and lockout reports still
+// being processed in the background are done, or ctx is
+func WaitForBackgroundJobs(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		for _, q := range backgroundQueues {
+			q.pending.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
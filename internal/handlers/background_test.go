@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBackgroundQueue(t *testing.T) {
+	q := newBackgroundQueue(1)
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/forgot", nil)
+
+	release := make(chan struct{})
+	var ran atomic.Int32
+	if !q.start(httptest.NewRecorder(), r, func() { <-release; ran.Add(1) }) {
+		t.Fatal("first job was turned away")
+	}
+
+	// The only slot is taken, so the next job is turned away
+	w := httptest.NewRecorder()
+	if q.start(w, r, func() { ran.Add(1) }) {
+		t.Fatal("job started although the queue is full")
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	q.pending.Wait()
+	if got := ran.Load(); got != 1 {
+		t.Fatalf("%d jobs ran, want 1", got)
+	}
+
+	// The slot is free again
+	if !q.start(httptest.NewRecorder(), r, func() { ran.Add(1) }) {
+		t.Fatal("job turned away after the queue drained")
+	}
+	q.pending.Wait()
+	if got := ran.Load(); got != 2 {
+		t.Fatalf("%d jobs ran, want 2", got)
+	}
+}
+
+func TestBackgroundQueueTryStart(t *testing.T) {
+	q := newBackgroundQueue(1)
+
+	release := make(chan struct{})
+	var ran atomic.Int32
+	if !q.tryStart(func() { <-release; ran.Add(1) }) {
+		t.Fatal("first job was dropped")
+	}
+
+	// The only slot is taken, so the next job is dropped without waiting
+	if q.tryStart(func() { ran.Add(1) }) {
+		t.Fatal("job started although the queue is full")
+	}
+
+	close(release)
+	q.pending.Wait()
+	if got := ran.Load(); got != 1 {
+		t.Fatalf("%d jobs ran, want 1", got)
+	}
+}
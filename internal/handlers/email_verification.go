@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// verificationResendCooldown is the minimum time between two verification emails
+const verificationResendCooldown = time.Minute
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"<verification_token>"`
+}
+
+// MessageResponse is a generic message payload
+type MessageResponse struct {
+	Message string `json:"message" example:"Email verified"`
+}
+
+// @Summary      Verify email
+// @Description  Confirm the email address using the single-use token sent by email.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      VerifyEmailRequest  true  "Verification token"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid or expired token"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/verify-email [post]
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req VerifyEmailRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := utils.ConsumeUserToken(ctx, models.TokenPurposeEmailVerification, req.Token)
+	if errors.Is(err, utils.ErrInvalidUserToken) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	now := time.Now()
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"emailVerified":   true,
+			"emailVerifiedAt": now,
+			"updatedAt":       now,
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Email verified",
+	})
+}
+
+// @Summary      Resend verification email
+// @Description  Send a new verification email to the current user. Previous links stop working.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Email already verified"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      429  {object}  ErrorResponse  "Too many requests"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/verify-email/resend [post]
+func ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.EmailVerified {
+		utils.ApiError(w, http.StatusBadRequest, "Email already verified")
+		return
+	}
+
+	issuedAt, err := utils.LatestUserTokenIssuedAt(ctx, userID, models.TokenPurposeEmailVerification)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+	if time.Since(issuedAt) < verificationResendCooldown {
+		utils.ApiError(w, http.StatusTooManyRequests, "Please wait before requesting another email")
+		return
+	}
+
+	if err := sendVerificationEmail(ctx, userID, user.Email); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Verification email sent",
+	})
+}
+
+// sendVerificationEmail issues a fresh verification token and emails the link
+func sendVerificationEmail(ctx context.Context, userID primitive.ObjectID, email string) error {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+
+	token, err := utils.IssueUserToken(ctx, userID, models.TokenPurposeEmailVerification, cfg.Auth.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.Server.FrontendURL + "/verify-email?token=" + url.QueryEscape(token)
+	return mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: "Welcome!\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			fmt.Sprintf("The link expires in %s. If you did not sign up, you can ignore this email.\n", cfg.Auth.EmailVerificationTTL),
+	})
+}
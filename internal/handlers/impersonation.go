@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason" example:"Ticket #1234: student cannot see their profile"`
+}
+
+// ImpersonationResponse carries an access token for acting as another user
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"userId"    example:"64b7f0c2a1b2c3d4e5f60718"`
+	ExpiresAt time.Time `json:"expiresAt" example:"2025-01-01T12:15:00Z"`
+}
+
+// @Summary      Impersonate user
+// @Description  Get a short-lived access token to see the API as the user does. It cannot be refreshed, is not set as a cookie, and cannot change the password, sessions, two-factor settings, passkeys or verification. Every request made with it is logged and recorded in the audit log. It is revoked when either account is logged out everywhere, and stops working if the admin is suspended or loses the admin role. Admins cannot be impersonated.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                  true  "User ID"
+// @Param        payload  body      ImpersonateUserRequest  true  "Why support needs to act as the user"
+// @Success      200      {object}  ImpersonationResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid user id, own account or missing reason"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  ErrorResponse  "Forbidden, target is an admin or suspended"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/admin/users/{id}/impersonate [post]
+func AdminImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	adminID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+	if userID == adminID {
+		utils.ApiError(w, http.StatusBadRequest, "You cannot impersonate yourself")
+		return
+	}
+
+	var req ImpersonateUserRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	err = users.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"roles": 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	}
+	// Acting as another admin would be a way around their own audit trail
+	if slices.Contains(user.EffectiveRoles(), models.RoleAdmin) {
+		utils.ApiError(w, http.StatusForbidden, "Admins cannot be impersonated")
+		return
+	}
+
+	token, impersonation, err := utils.GenerateImpersonationToken(ctx, adminID, userID)
+	if errors.Is(err, utils.ErrAccountSuspended) {
+		utils.ApiError(w, http.StatusForbidden, "Account suspended")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate access token")
+		return
+	}
+
+	event := utils.NewAuthEvent(r, models.AuthEventImpersonationStart, models.AuthOutcomeSuccess)
+	event.UserID = &userID
+	event.ActorID = &adminID
+	event.Reason = req.Reason
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	utils.ApiResponse(w, http.StatusOK, ImpersonationResponse{
+		Token:     token,
+		UserID:    userID.Hex(),
+		ExpiresAt: impersonation.ExpiresAt.Time,
+	})
+}
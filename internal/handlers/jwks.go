@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/jwtkeys"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+)
+
+// JWKS publishes the public keys other services use to verify access tokens
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying access tokens, selected by the token's kid header. Empty while tokens are signed with HS256.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  jwtkeys.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	// Short cache so a rotated-in key reaches verifiers quickly
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.ApiResponse(w, http.StatusOK, jwtkeys.JWKS())
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+)
+
+// tooManyLoginAttempts answers 429 with a Retry-After header
+func tooManyLoginAttempts(w http.ResponseWriter, lockedUntil time.Time) {
+	retry := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+	utils.ApiError(w, http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+}
+
+// loginFailed counts a failed password login for identifier and answers
+// 401, or 429 once the identifier is locked. user is nil when no account
+// matched.
+func loginFailed(ctx context.Context, w http.ResponseWriter, r *http.Request, identifier string, user *models.User) {
+	if lockedUntil := countLoginFailure(ctx, r, identifier, models.AuthMethodPassword, user); !lockedUntil.IsZero() {
+		tooManyLoginAttempts(w, lockedUntil)
+		return
+	}
+	utils.ApiError(w, http.StatusUnauthorized, "Invalid credentials")
+}
+
+// countLoginFailure records a failed login with method for identifier and
+// returns the end of the lockout, or the zero time if not locked. user is
+// nil when no account matched.
+func countLoginFailure(ctx context.Context, r *http.Request, identifier, method string, user *models.User) time.Time {
+	lockedUntil, newlyLocked, err := utils.RecordLoginFailure(ctx, identifier, utils.ClientIP(r))
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+
+	// Reported in the background so known and unknown identifiers answer
+	// equally fast
+	if newlyLocked && user != nil {
+		queueLockoutReport(r, *user, lockedUntil)
+	}
+
+	event := loginEvent(r, models.AuthOutcomeFailure, method, user)
+	event.Identifier = identifier
+	event.Reason = "invalid_credentials"
+	if newlyLocked {
+		event.Reason = "invalid_credentials_locked"
+	}
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	return lockedUntil
+}
+
+// loginLocked answers 429 and returns true while identifier is locked
+func loginLocked(ctx context.Context, w http.ResponseWriter, r *http.Request, identifier, method string) bool {
+	lockedUntil, err := utils.LoginLockedUntil(ctx, identifier)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return true
+	}
+	if lockedUntil.IsZero() {
+		return false
+	}
+
+	event := loginEvent(r, models.AuthOutcomeFailure, method, nil)
+	event.Reason = "locked"
+	event.Identifier = identifier
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	tooManyLoginAttempts(w, lockedUntil)
+	return true
+}
+
+// mfaFailed counts a wrong second factor against every identifier of the
+// user, so logging in with the password again does not reset the budget.
+// Returns the end of the lockout, or the zero time if not locked.
+func mfaFailed(ctx context.Context, r *http.Request, user *models.User) time.Time {
+	var lockedUntil time.Time
+	newlyLocked := false
+	for _, identifier := range loginIdentifiers(user) {
+		until, locked, err := utils.RecordLoginFailure(ctx, identifier, utils.ClientIP(r))
+		if err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+			continue
+		}
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+		newlyLocked = newlyLocked || locked
+	}
+
+	if newlyLocked {
+		queueLockoutReport(r, *user, lockedUntil)
+	}
+	return lockedUntil
+}
+
+// secondFactorLocked answers 429 and returns true while the user's login
+// is locked, so codes cannot be guessed outside the login flow either
+func secondFactorLocked(ctx context.Context, w http.ResponseWriter, user *models.User) bool {
+	lockedUntil, err := loginLockedUntil(ctx, user)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return true
+	}
+	if !lockedUntil.IsZero() {
+		tooManyLoginAttempts(w, lockedUntil)
+		return true
+	}
+	return false
+}
+
+// secondFactorFailed counts a wrong code (or password) given to confirm an
+// account change toward the login lockout, and answers 429 once locked or
+// 400 with msg otherwise
+func secondFactorFailed(ctx context.Context, w http.ResponseWriter, r *http.Request, user *models.User, msg string) {
+	if lockedUntil := mfaFailed(ctx, r, user); !lockedUntil.IsZero() {
+		tooManyLoginAttempts(w, lockedUntil)
+		return
+	}
+	utils.ApiError(w, http.StatusBadRequest, msg)
+}
+
+// loginLockedUntil returns when the lockout of the user's identifiers ends,
+// or the zero time if none is locked
+func loginLockedUntil(ctx context.Context, user *models.User) (time.Time, error) {
+	var lockedUntil time.Time
+	for _, identifier := range loginIdentifiers(user) {
+		until, err := utils.LoginLockedUntil(ctx, identifier)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+	return lockedUntil, nil
+}
+
+// clearLoginFailures resets the failure counters of the user's identifiers
+// once a login has fully succeeded
+func clearLoginFailures(ctx context.Context, user *models.User) {
+	for _, identifier := range loginIdentifiers(user) {
+		if err := utils.ClearLoginFailures(ctx, identifier); err != nil {
+			log.Printf("Failed to clear login failures: %v", err)
+		}
+	}
+}
+
+// loginIdentifiers returns the identifiers the user can log in with, in the
+// form Login looks them up
+func loginIdentifiers(user *models.User) []string {
+	var identifiers []string
+	for _, v := range []string{user.Email, user.Phone} {
+		if v = strings.TrimSpace(strings.ToLower(v)); v != "" {
+			identifiers = append(identifiers, v)
+		}
+	}
+	return identifiers
+}
+
+// queueLockoutReport reports a new lockout in the background. When the
+// queue is full the report is dropped rather than delaying the response.
+func queueLockoutReport(r *http.Request, user models.User, lockedUntil time.Time) {
+	ip, userAgent := utils.ClientIP(r), r.UserAgent()
+	if !lockoutQueue.tryStart(func() { reportLockout(user, ip, userAgent, lockedUntil) }) {
+		log.Printf("Lockout queue full, report for user %s dropped", user.ID.Hex())
+	}
+}
+
+// reportLockout records a security event and, if enabled, emails the
+// account owner that password login is locked
+func reportLockout(user models.User, ip, userAgent string, lockedUntil time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_ = utils.RecordSecurityEvent(ctx, models.SecurityEvent{
+		Type:      models.SecurityEventAccountLocked,
+		UserID:    &user.ID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Details:   fmt.Sprintf("too many failed logins; locked until %s", lockedUntil.UTC().Format(time.RFC3339)),
+	})
+
+	cfg := config.AppConfig
+	if cfg == nil || !cfg.Auth.LoginLockoutNotify || user.Email == "" {
+		return
+	}
+
+	err := mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Sign-in temporarily locked",
+		Body: "There were several failed attempts to sign in to your account, so password sign-in is locked until " +
+			lockedUntil.UTC().Format("2006-01-02 15:04 MST") + ".\n\n" +
+			"If this was you, wait and try again, or reset your password from the sign-in page.\n\n" +
+			"If it was not you, your password is still safe, but consider changing it and turning on two-factor authentication.\n",
+	})
+	if err != nil {
+		log.Printf("Failed to send lockout email: %v", err)
+	}
+}
@@ -0,0 +1,667 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/totp"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes are issued at a time
+	recoveryCodeCount = 10
+	// totpSkew allows one step of clock drift either way
+	totpSkew = 1
+)
+
+// MFAChallengeResponse is returned by login when a second factor is required
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired" example:"true"`
+	MFAToken    string `json:"mfaToken"    example:"<mfa_challenge_token>"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfaToken"               example:"<mfa_challenge_token>"`
+	Code         string `json:"code,omitempty"         example:"123456"`
+	RecoveryCode string `json:"recoveryCode,omitempty" example:"abcd-efgh"`
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"     example:"JBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauthUri" example:"otpauth://totp/Uni%20Backend:user@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Uni+Backend"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+type DisableTOTPRequest struct {
+	Password     string `json:"password"               example:"password123"`
+	Code         string `json:"code,omitempty"         example:"123456"`
+	RecoveryCode string `json:"recoveryCode,omitempty" example:"abcd-efgh"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes" example:"abcd-efgh,ijkl-mnop"`
+}
+
+// finishLogin completes a first-factor login: users with two-factor
+// authentication get an MFA challenge, everyone else gets tokens. method is
+// recorded in the audit log.
+func finishLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Configuration not loaded")
+		return
+	}
+	if rejectSuspended(ctx, w, r, user, method) {
+		return
+	}
+
+	if user.MFAEnabled {
+		challenge, err := utils.IssueUserToken(ctx, user.ID, models.TokenPurposeMFAChallenge, cfg.Auth.MFAChallengeTTL)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to start two-factor authentication")
+			return
+		}
+		utils.ApiResponse(w, http.StatusAccepted, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+		})
+		return
+	}
+
+	issueLoginTokens(ctx, w, r, user, method)
+}
+
+// issueLoginTokens starts a session for a fully authenticated user and
+// writes the tokens as cookies and in the response body
+func issueLoginTokens(ctx context.Context, w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	if rejectSuspended(ctx, w, r, user, method) {
+		return
+	}
+
+	access, refresh, err := utils.IssueTokens(ctx, r, user.ID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate authentication tokens")
+		return
+	}
+	_ = utils.RecordAuthEvent(ctx, loginEvent(r, models.AuthOutcomeSuccess, method, user))
+	clearLoginFailures(ctx, user)
+
+	utils.SetAccessCookie(w, access)
+	utils.SetRefreshCookie(w, refresh)
+
+	utils.ApiResponse(w, http.StatusOK, AuthResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		User:         userSummary(user),
+	})
+}
+
+// rejectSuspended answers 403 for suspended accounts and reports whether it did
+func rejectSuspended(ctx context.Context, w http.ResponseWriter, r *http.Request, user *models.User, method string) bool {
+	if !user.Suspended {
+		return false
+	}
+
+	event := loginEvent(r, models.AuthOutcomeFailure, method, user)
+	event.Reason = "suspended"
+	_ = utils.RecordAuthEvent(ctx, event)
+
+	utils.ApiError(w, http.StatusForbidden, "Account suspended")
+	return true
+}
+
+// loginEvent starts an audit log entry for a login attempt. user may be nil.
+func loginEvent(r *http.Request, outcome, method string, user *models.User) models.AuthEvent {
+	event := utils.NewAuthEvent(r, models.AuthEventLogin, outcome)
+	event.Method = method
+	if user != nil {
+		event.UserID = &user.ID
+	}
+	return event
+}
+
+// @Summary      Complete two-factor login
+// @Description  Exchange the MFA challenge token from login plus a TOTP or recovery code for access & refresh tokens (also set as cookies). Wrong codes count towards the same lockout as wrong passwords.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      MFALoginRequest  true  "Challenge token and second factor"
+// @Success      200      {object}  AuthResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      401      {object}  ErrorResponse  "Invalid code or expired challenge"
+// @Failure      403      {object}  ErrorResponse  "Account suspended"
+// @Failure      429      {object}  ErrorResponse  "Too many attempts"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/login/mfa [post]
+func LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	cfg := config.AppConfig
+	if cfg == nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Configuration not loaded")
+		return
+	}
+
+	var req MFALoginRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.MFAToken = strings.TrimSpace(req.MFAToken)
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		utils.ApiError(w, http.StatusBadRequest, "Missing challenge token or code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := utils.FindUserToken(ctx, models.TokenPurposeMFAChallenge, req.MFAToken)
+	if errors.Is(err, utils.ErrInvalidUserToken) {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired challenge, please log in again")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil || !user.MFAEnabled {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired challenge, please log in again")
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	lockedUntil, err := loginLockedUntil(ctx, &user)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return
+	}
+	if !lockedUntil.IsZero() {
+		event := loginEvent(r, models.AuthOutcomeFailure, models.AuthMethodMFA, &user)
+		event.Reason = "locked"
+		_ = utils.RecordAuthEvent(ctx, event)
+
+		tooManyLoginAttempts(w, lockedUntil)
+		return
+	}
+
+	ok, err := verifySecondFactor(ctx, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if !ok {
+		lockedUntil := mfaFailed(ctx, r, &user)
+
+		event := loginEvent(r, models.AuthOutcomeFailure, models.AuthMethodMFA, &user)
+		event.Reason = "invalid_code"
+		if !lockedUntil.IsZero() {
+			event.Reason = "invalid_code_locked"
+		}
+		_ = utils.RecordAuthEvent(ctx, event)
+
+		if !lockedUntil.IsZero() {
+			_, _ = utils.ConsumeUserToken(ctx, models.TokenPurposeMFAChallenge, req.MFAToken)
+			tooManyLoginAttempts(w, lockedUntil)
+			return
+		}
+
+		deleted, err := utils.FailUserToken(ctx, models.TokenPurposeMFAChallenge, req.MFAToken, cfg.Auth.MFAMaxAttempts)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+			return
+		}
+		if deleted {
+			utils.ApiError(w, http.StatusTooManyRequests, "Too many attempts, please log in again")
+			return
+		}
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	// Single use: a concurrent request with the same challenge loses here
+	if _, err := utils.ConsumeUserToken(ctx, models.TokenPurposeMFAChallenge, req.MFAToken); err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired challenge, please log in again")
+		return
+	}
+
+	issueLoginTokens(ctx, w, r, &user, models.AuthMethodMFA)
+}
+
+// @Summary      Start TOTP enrollment
+// @Description  Generate a new TOTP secret for the current user. It becomes active once confirmed with a code.
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  TOTPSetupResponse
+// @Failure      400  {object}  ErrorResponse  "Two-factor authentication already enabled"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/mfa/totp/setup [post]
+func SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	cfg := config.AppConfig
+	if cfg == nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Configuration not loaded")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.MFAEnabled {
+		utils.ApiError(w, http.StatusBadRequest, "Two-factor authentication already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"totpPendingSecret": secret,
+			"updatedAt":         time.Now(),
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(cfg.Auth.MFAIssuer, user.Email, secret),
+	})
+}
+
+// @Summary      Confirm TOTP enrollment
+// @Description  Enable two-factor authentication with a code from the authenticator app. Returns one-time recovery codes; they are shown only once.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success      200      {object}  RecoveryCodesResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid code or no enrollment in progress"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/mfa/totp/confirm [post]
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req TOTPCodeRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.MFAEnabled {
+		utils.ApiError(w, http.StatusBadRequest, "Two-factor authentication already enabled")
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		utils.ApiError(w, http.StatusBadRequest, "No enrollment in progress")
+		return
+	}
+
+	step, ok := totp.Validate(user.TOTPPendingSecret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	res, err := users.UpdateOne(ctx,
+		bson.M{"_id": userID, "totpPendingSecret": user.TOTPPendingSecret},
+		bson.M{
+			"$set": bson.M{
+				"mfaEnabled":         true,
+				"totpSecret":         user.TOTPPendingSecret,
+				"totpLastUsedStep":   step,
+				"recoveryCodeHashes": hashes,
+				"updatedAt":          time.Now(),
+			},
+			"$unset": bson.M{"totpPendingSecret": ""},
+		},
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	if res.MatchedCount == 0 {
+		utils.ApiError(w, http.StatusBadRequest, "No enrollment in progress")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary      Disable TOTP
+// @Description  Turn off two-factor authentication. Requires the current password and a TOTP or recovery code. Accounts without a password leave it out, but must have logged in within the last few minutes. Wrong passwords and codes count towards the login lockout.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      DisableTOTPRequest  true  "Password and second factor"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid password or code"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized, or login too old for an account without a password"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      429      {object}  ErrorResponse  "Too many failed attempts; see Retry-After"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/mfa/totp/disable [post]
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if !user.MFAEnabled {
+		utils.ApiError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	// Wrong passwords and codes count towards the login lockout
+	if secondFactorLocked(ctx, w, &user) {
+		return
+	}
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			secondFactorFailed(ctx, w, r, &user, "Invalid password or code")
+			return
+		}
+	} else {
+		recent, err := recentlyLoggedIn(ctx, claims)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to check session")
+			return
+		}
+		if !recent {
+			utils.ApiError(w, http.StatusUnauthorized, "Please log in again to turn off two-factor authentication")
+			return
+		}
+	}
+	ok, err = verifySecondFactor(ctx, &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if !ok {
+		secondFactorFailed(ctx, w, r, &user, "Invalid password or code")
+		return
+	}
+
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"mfaEnabled": false,
+			"updatedAt":  time.Now(),
+		},
+		"$unset": bson.M{
+			"totpSecret":         "",
+			"totpPendingSecret":  "",
+			"totpLastUsedStep":   "",
+			"recoveryCodeHashes": "",
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// @Summary      Regenerate recovery codes
+// @Description  Replace all recovery codes with new ones. Requires a current TOTP code; wrong codes count towards the login lockout.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success      200      {object}  RecoveryCodesResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid code"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      429      {object}  ErrorResponse  "Too many failed attempts; see Retry-After"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req TOTPCodeRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if !user.MFAEnabled {
+		utils.ApiError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	// Wrong codes count towards the login lockout
+	if secondFactorLocked(ctx, w, &user) {
+		return
+	}
+	ok, err = verifySecondFactor(ctx, &user, req.Code, "")
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if !ok {
+		secondFactorFailed(ctx, w, r, &user, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"recoveryCodeHashes": hashes,
+			"updatedAt":          time.Now(),
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to store recovery codes")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code for the user.
+// Both are single use: a TOTP step cannot be replayed and a recovery code is
+// removed once it has been used.
+func verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		res, err := users.UpdateOne(ctx,
+			bson.M{
+				"_id": user.ID,
+				"$or": []bson.M{
+					{"totpLastUsedStep": bson.M{"$lt": step}},
+					{"totpLastUsedStep": bson.M{"$exists": false}},
+				},
+			},
+			bson.M{"$set": bson.M{"totpLastUsedStep": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return res.MatchedCount == 1, nil
+	}
+
+	if recoveryCode != "" {
+		hash := utils.SHA256Hex(normalizeRecoveryCode(recoveryCode))
+		res, err := users.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "recoveryCodeHashes": hash},
+			bson.M{"$pull": bson.M{"recoveryCodeHashes": hash}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return res.ModifiedCount == 1, nil
+	}
+
+	return false, nil
+}
+
+// generateRecoveryCodes returns fresh recovery codes and their hashes
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := totp.GenerateSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(raw[:4] + "-" + raw[4:8])
+		codes = append(codes, code)
+		hashes = append(hashes, utils.SHA256Hex(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/oidc"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errUnverifiedProviderEmail = errors.New("provider did not return a verified email")
+
+type OAuthProvidersResponse struct {
+	Providers []string `json:"providers" example:"google"`
+}
+
+type OAuthStartResponse struct {
+	AuthorizationURL string `json:"authorizationUrl" example:"https://accounts.google.com/o/oauth2/v2/auth?..."`
+}
+
+// OAuthCallbackRequest carries the query parameters the provider redirected
+// the browser back with
+type OAuthCallbackRequest struct {
+	Code  string `json:"code"  example:"<authorization_code>"`
+	State string `json:"state" example:"<state>"`
+}
+
+// @Summary      List social login providers
+// @Description  List the configured OpenID Connect providers.
+// @Tags         oauth
+// @Produce      json
+// @Success      200  {object}  OAuthProvidersResponse
+// @Router       /api/v1/auth/oauth/providers [get]
+func ListOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, OAuthProvidersResponse{Providers: oidc.Names()})
+}
+
+// @Summary      Start social login
+// @Description  Create an authorization request (state, nonce and PKCE) and return the provider URL to send the browser to. The provider redirects back to the frontend, which posts the code and state to the callback endpoint.
+// @Tags         oauth
+// @Produce      json
+// @Param        provider  path      string  true  "Provider name, e.g. google"
+// @Success      200       {object}  OAuthStartResponse
+// @Failure      404       {object}  ErrorResponse  "Unknown provider"
+// @Failure      502       {object}  ErrorResponse  "Provider unavailable"
+// @Failure      500       {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/oauth/{provider}/start [get]
+func StartOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	provider, err := oidc.Get(r.PathValue("provider"))
+	if err != nil {
+		utils.ApiError(w, http.StatusNotFound, "Unknown provider")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	state, nonce, challenge, err := utils.BeginOAuthLogin(ctx, provider.Name())
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		log.Printf("OIDC start failed for %s: %v", provider.Name(), err)
+		utils.ApiError(w, http.StatusBadGateway, "Provider unavailable")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, OAuthStartResponse{AuthorizationURL: authURL})
+}
+
+// @Summary      Complete social login
+// @Description  Exchange the authorization code for an ID token, then sign in the linked account. An existing account with the same verified email is linked; otherwise a new account is created. Accounts with two-factor authentication get an MFA challenge token instead.
+// @Tags         oauth
+// @Accept       json
+// @Produce      json
+// @Param        provider  path      string                true  "Provider name, e.g. google"
+// @Param        payload   body      OAuthCallbackRequest  true  "Code and state from the redirect"
+// @Success      200       {object}  AuthResponse
+// @Success      202       {object}  MFAChallengeResponse  "Two-factor authentication required"
+// @Failure      400       {object}  ErrorResponse  "Invalid or expired login"
+// @Failure      401       {object}  ErrorResponse  "Provider login could not be verified"
+// @Failure      403       {object}  ErrorResponse  "No verified provider email, or account suspended"
+// @Failure      404       {object}  ErrorResponse  "Unknown provider"
+// @Failure      500       {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/oauth/{provider}/callback [post]
+func OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	provider, err := oidc.Get(r.PathValue("provider"))
+	if err != nil {
+		utils.ApiError(w, http.StatusNotFound, "Unknown provider")
+		return
+	}
+
+	var req OAuthCallbackRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Code == "" || req.State == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing code or state")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	state, err := utils.TakeOAuthState(ctx, provider.Name(), req.State)
+	if errors.Is(err, utils.ErrInvalidOAuthState) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired login, please try again")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to complete login")
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange failed for %s: %v", provider.Name(), err)
+		utils.ApiError(w, http.StatusUnauthorized, "Provider login could not be verified")
+		return
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC id token rejected for %s: %v", provider.Name(), err)
+		utils.ApiError(w, http.StatusUnauthorized, "Provider login could not be verified")
+		return
+	}
+
+	user, err := resolveOAuthUser(ctx, provider.Name(), claims)
+	if errors.Is(err, errUnverifiedProviderEmail) {
+		utils.ApiError(w, http.StatusForbidden, "Your provider account has no verified email address")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC account resolution failed for %s: %v", provider.Name(), err)
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to complete login")
+		return
+	}
+
+	finishLogin(ctx, w, r, user, models.AuthMethodOAuth+":"+provider.Name())
+}
+
+// resolveOAuthUser finds the account for a provider identity: an already
+// linked account, else an account with the same verified email (which gets
+// linked), else a new account
+func resolveOAuthUser(ctx context.Context, provider string, claims *oidc.Claims) (*models.User, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	err := users.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": claims.Subject}},
+	}).Decode(&user)
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	if email == "" || !claims.EmailVerified {
+		return nil, errUnverifiedProviderEmail
+	}
+
+	now := time.Now()
+	identity := models.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+		LinkedAt: now,
+	}
+
+	err = users.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == nil {
+		update := bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set": bson.M{
+				"emailVerified": true,
+				"updatedAt":     now,
+			},
+		}
+		if !user.EmailVerified {
+			// Nobody had proven control of this email, so the existing
+			// credentials may belong to someone else: drop them and sign
+			// out its sessions. The owner can set a password via
+			// forgot-password.
+			set := update["$set"].(bson.M)
+			set["emailVerifiedAt"] = now
+			set["mfaEnabled"] = false
+			update["$unset"] = bson.M{
+				"password":           "",
+				"totpSecret":         "",
+				"totpPendingSecret":  "",
+				"totpLastUsedStep":   "",
+				"recoveryCodeHashes": "",
+			}
+			passkeys := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+			if _, err := passkeys.DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+				return nil, err
+			}
+			if err := utils.RevokeAllSessions(ctx, user.ID, models.RevocationReasonAccountLinked); err != nil {
+				return nil, err
+			}
+		}
+
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := users.FindOneAndUpdate(ctx, bson.M{"_id": user.ID}, update, opts).Decode(&user); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	user = models.User{
+		Email:           email,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+		Identities:      []models.Identity{identity},
+		Roles:           []string{models.RoleStudent},
+		FullName:        claims.Name,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	res, err := users.InsertOne(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = res.InsertedID.(primitive.ObjectID)
+	return &user, nil
+}
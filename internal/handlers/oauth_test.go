@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/oidc"
+	"github.com/MH-PAVEL/uni-backend-go/internal/testutil"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// insertTestUser stores user with the given password (none when empty)
+func insertTestUser(t *testing.T, user models.User, password string) models.User {
+	t.Helper()
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Password = string(hash)
+	}
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	if len(user.Roles) == 0 {
+		user.Roles = []string{models.RoleStudent}
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.InsertOne(context.Background(), user)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	user.ID = res.InsertedID.(primitive.ObjectID)
+	return user
+}
+
+func loadTestUser(t *testing.T, id primitive.ObjectID) models.User {
+	t.Helper()
+	var user models.User
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	if err := users.FindOne(context.Background(), bson.M{"_id": id}).Decode(&user); err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	return user
+}
+
+func countTestDocuments(t *testing.T, collection string, filter bson.M) int64 {
+	t.Helper()
+	n, err := database.GetCollection(database.DbName(), collection).CountDocuments(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("count %s: %v", collection, err)
+	}
+	return n
+}
+
+func providerClaims(subject, email string, verified bool) *oidc.Claims {
+	return &oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+		Email:            email,
+		EmailVerified:    verified,
+		Name:             "Provider User",
+	}
+}
+
+func TestResolveOAuthUser(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+	r := httptest.NewRequest("GET", "/api/v1/auth/oauth/test/callback", nil)
+
+	t.Run("creates an account", func(t *testing.T) {
+		user, err := resolveOAuthUser(ctx, "test", providerClaims("new-sub", "New@Example.com", true))
+		if err != nil {
+			t.Fatalf("resolveOAuthUser: %v", err)
+		}
+		if user.Email != "new@example.com" || !user.EmailVerified || user.Password != "" {
+			t.Fatalf("unexpected user %+v", user)
+		}
+		if len(user.Identities) != 1 || user.Identities[0].Subject != "new-sub" {
+			t.Fatalf("identities = %+v", user.Identities)
+		}
+
+		again, err := resolveOAuthUser(ctx, "test", providerClaims("new-sub", "changed@example.com", true))
+		if err != nil {
+			t.Fatalf("resolveOAuthUser for a linked identity: %v", err)
+		}
+		if again.ID != user.ID {
+			t.Fatalf("linked identity resolved to %s, want %s", again.ID.Hex(), user.ID.Hex())
+		}
+	})
+
+	t.Run("refuses unverified provider emails", func(t *testing.T) {
+		for _, claims := range []*oidc.Claims{
+			providerClaims("unverified-sub", "unverified@example.com", false),
+			providerClaims("no-email-sub", "", true),
+		} {
+			if _, err := resolveOAuthUser(ctx, "test", claims); !errors.Is(err, errUnverifiedProviderEmail) {
+				t.Fatalf("err = %v, want %v", err, errUnverifiedProviderEmail)
+			}
+		}
+		if n := countTestDocuments(t, database.UsersCollection, bson.M{"email": "unverified@example.com"}); n != 0 {
+			t.Fatalf("created %d users for an unverified email", n)
+		}
+	})
+
+	t.Run("links to a verified account", func(t *testing.T) {
+		existing := insertTestUser(t, models.User{
+			Email:         "verified@example.com",
+			EmailVerified: true,
+			MFAEnabled:    true,
+			TOTPSecret:    "SECRET",
+		}, "correct horse battery")
+		if _, _, err := utils.CreateSession(ctx, r, existing.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := resolveOAuthUser(ctx, "test", providerClaims("verified-sub", "verified@example.com", true))
+		if err != nil {
+			t.Fatalf("resolveOAuthUser: %v", err)
+		}
+		if user.ID != existing.ID {
+			t.Fatalf("linked to %s, want %s", user.ID.Hex(), existing.ID.Hex())
+		}
+
+		stored := loadTestUser(t, existing.ID)
+		if len(stored.Identities) != 1 || stored.Identities[0].Provider != "test" {
+			t.Fatalf("identities = %+v", stored.Identities)
+		}
+		if stored.Password == "" || !stored.MFAEnabled || stored.TOTPSecret == "" {
+			t.Fatal("linking dropped the credentials of a verified account")
+		}
+		if n := countTestDocuments(t, database.SessionsCollection, bson.M{"userId": existing.ID}); n != 1 {
+			t.Fatalf("verified account has %d sessions after linking, want 1", n)
+		}
+	})
+
+	t.Run("strips credentials from an unverified account", func(t *testing.T) {
+		existing := insertTestUser(t, models.User{
+			Email:      "squatter@example.com",
+			MFAEnabled: true,
+			TOTPSecret: "SECRET",
+		}, "set by someone else")
+		if _, _, err := utils.CreateSession(ctx, r, existing.ID); err != nil {
+			t.Fatal(err)
+		}
+		passkeys := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+		if _, err := passkeys.InsertOne(ctx, models.WebAuthnCredential{UserID: existing.ID, CredentialID: "cred-1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := resolveOAuthUser(ctx, "test", providerClaims("owner-sub", "squatter@example.com", true))
+		if err != nil {
+			t.Fatalf("resolveOAuthUser: %v", err)
+		}
+		if user.ID != existing.ID {
+			t.Fatalf("linked to %s, want %s", user.ID.Hex(), existing.ID.Hex())
+		}
+
+		stored := loadTestUser(t, existing.ID)
+		if !stored.EmailVerified {
+			t.Fatal("email not marked verified")
+		}
+		if stored.Password != "" || stored.MFAEnabled || stored.TOTPSecret != "" {
+			t.Fatalf("credentials kept: password=%t mfa=%t totp=%t", stored.Password != "", stored.MFAEnabled, stored.TOTPSecret != "")
+		}
+		if n := countTestDocuments(t, database.WebAuthnCredentialsCollection, bson.M{"userId": existing.ID}); n != 0 {
+			t.Fatalf("%d passkeys kept", n)
+		}
+		if n := countTestDocuments(t, database.SessionsCollection, bson.M{"userId": existing.ID}); n != 0 {
+			t.Fatalf("%d sessions kept", n)
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/password"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetCooldown is the minimum time between two reset emails to the
+// same account
+const passwordResetCooldown = time.Minute
+
+type ForgotPasswordRequest struct {
+	Identifier string `json:"identifier" example:"F2HbU@example.com"` // email or phone
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"       example:"<reset_token>"`
+	NewPassword string `json:"newPassword" example:"newPassword123"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" example:"password123"`
+	NewPassword     string `json:"newPassword"     example:"newPassword123"`
+}
+
+// @Summary      Forgot password
+// @Description  Email a password reset link to the account with the given email or phone. At most one link is sent per account per minute. The response is the same whether or not the account exists.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      ForgotPasswordRequest  true  "Account identifier"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      503      {object}  ErrorResponse  "Too many requests at once; see Retry-After"
+// @Router       /api/v1/auth/password/forgot [post]
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	identifier := strings.TrimSpace(strings.ToLower(req.Identifier))
+	if identifier == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing identifier")
+		return
+	}
+
+	// Look the account up and send the email in the background so the
+	// response time does not reveal whether the identifier exists
+	if !passwordResetQueue.start(w, r, func() { sendPasswordResetEmail(identifier) }) {
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "If an account exists for this identifier, a password reset link has been sent",
+	})
+}
+
+// @Summary      Reset password
+// @Description  Set a new password using the single-use token from the reset email. All sessions of the user are revoked.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      ResetPasswordRequest  true  "Reset token and new password"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid or expired token, or password rejected by policy"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/password/reset [post]
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Check the token without using it up, so a rejected password can be retried
+	userID, err := utils.FindUserToken(ctx, models.TokenPurposePasswordReset, req.Token)
+	if errors.Is(err, utils.ErrInvalidUserToken) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if err := password.Validate(req.NewPassword, user.Email, user.Phone); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Could not hash password")
+		return
+	}
+
+	// Use the token up; a concurrent reset with the same token loses here
+	if _, err := utils.ConsumeUserToken(ctx, models.TokenPurposePasswordReset, req.Token); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"password":  string(hash),
+			"updatedAt": time.Now(),
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Sign the user out everywhere
+	if err := utils.RevokeAllSessions(ctx, userID, models.RevocationReasonPasswordReset); err != nil {
+		log.Printf("Failed to revoke sessions after password reset: %v", err)
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Password has been reset, please log in again",
+	})
+}
+
+// @Summary      Change password
+// @Description  Change the current user's password. Every session is revoked and a fresh token pair is issued for this device (also set as cookies). Accounts without a password (passwordless or social login only) leave currentPassword out, but must have logged in within the last few minutes. Wrong current passwords count towards the login lockout.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      ChangePasswordRequest  true  "Current and new password"
+// @Success      200      {object}  AuthResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request, wrong current password or password rejected by policy"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized, or login too old for an account without a password"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      429      {object}  ErrorResponse  "Too many failed attempts; see Retry-After"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/password/change [post]
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if len(req.NewPassword) == 0 {
+		utils.ApiError(w, http.StatusBadRequest, "Missing password")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.Password != "" {
+		if len(req.CurrentPassword) == 0 {
+			utils.ApiError(w, http.StatusBadRequest, "Missing password")
+			return
+		}
+		if req.NewPassword == req.CurrentPassword {
+			utils.ApiError(w, http.StatusBadRequest, "New password must be different from the current one")
+			return
+		}
+		// Wrong passwords count towards the login lockout, so a stolen
+		// access token cannot be used to guess the password
+		if secondFactorLocked(ctx, w, &user) {
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			secondFactorFailed(ctx, w, r, &user, "Current password is incorrect")
+			return
+		}
+	} else {
+		// Nothing to confirm, so a fresh login stands in for the password
+		recent, err := recentlyLoggedIn(ctx, claims)
+		if err != nil {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to check session")
+			return
+		}
+		if !recent {
+			utils.ApiError(w, http.StatusUnauthorized, "Please log in again to set a password")
+			return
+		}
+	}
+
+	if err := password.Validate(req.NewPassword, user.Email, user.Phone); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Could not hash password")
+		return
+	}
+
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"password":  string(hash),
+			"updatedAt": time.Now(),
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	// Revoke every session (including this one) and the token used for this
+	// request, then start a fresh session for this device
+	if err := utils.RevokeAllSessions(ctx, userID, models.RevocationReasonPasswordChange); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+	if err := utils.RevokeAccessToken(ctx, claims.ID, userID, claims.ExpiresAt.Time, models.RevocationReasonPasswordChange); err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	access, refresh, err := utils.IssueTokens(ctx, r, userID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to generate authentication tokens")
+		return
+	}
+	utils.SetAccessCookie(w, access)
+	utils.SetRefreshCookie(w, refresh)
+
+	utils.ApiResponse(w, http.StatusOK, AuthResponse{
+		Token:        access,
+		RefreshToken: refresh,
+	})
+}
+
+// sendPasswordResetEmail emails a reset link if the identifier belongs to an account
+func sendPasswordResetEmail(identifier string) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	filter := bson.M{
+		"$or": []bson.M{
+			{"email": identifier},
+			{"phone": identifier},
+		},
+	}
+
+	var user models.User
+	if err := users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return
+	}
+
+	// Repeated requests are dropped quietly; the caller gets the same
+	// answer either way
+	issuedAt, err := utils.LatestUserTokenIssuedAt(ctx, user.ID, models.TokenPurposePasswordReset)
+	if err != nil {
+		log.Printf("Failed to look up password reset token: %v", err)
+		return
+	}
+	if time.Since(issuedAt) < passwordResetCooldown {
+		return
+	}
+
+	token, err := utils.IssueUserToken(ctx, user.ID, models.TokenPurposePasswordReset, cfg.Auth.PasswordResetTTL)
+	if err != nil {
+		log.Printf("Failed to issue password reset token: %v", err)
+		return
+	}
+
+	link := cfg.Server.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "We received a request to reset your password.\n\n" +
+			"Open the link below to choose a new one:\n\n" +
+			link + "\n\n" +
+			fmt.Sprintf("The link expires in %s and can be used once. If you did not ask for this, you can ignore this email.\n", cfg.Auth.PasswordResetTTL),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/sms"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasswordlessRequest struct {
+	Identifier string `json:"identifier" example:"F2HbU@example.com"` // email or phone
+	Method     string `json:"method"     example:"code" enums:"code,link"`
+}
+
+// PasswordlessVerifyRequest carries either identifier + code, or a magic link token
+type PasswordlessVerifyRequest struct {
+	Identifier string `json:"identifier,omitempty" example:"F2HbU@example.com"`
+	Code       string `json:"code,omitempty"       example:"123456"`
+	Token      string `json:"token,omitempty"      example:"<magic_link_token>"`
+}
+
+// @Summary      Request passwordless login
+// @Description  Send a one-time code or magic link to the email or phone of an account. Only verified email addresses and phone numbers receive one. The response is the same whether or not the account exists.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      PasswordlessRequest  true  "Identifier and delivery method"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      429      {object}  ErrorResponse  "Too many requests"
+// @Failure      503      {object}  ErrorResponse  "Too many requests at once; see Retry-After"
+// @Router       /api/v1/auth/passwordless/request [post]
+func RequestPasswordlessLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req PasswordlessRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	identifier := strings.TrimSpace(strings.ToLower(req.Identifier))
+	if identifier == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing identifier")
+		return
+	}
+	if req.Method == "" {
+		req.Method = "code"
+	}
+	if req.Method != "code" && req.Method != "link" {
+		utils.ApiError(w, http.StatusBadRequest, "Method must be code or link")
+		return
+	}
+
+	// Look the account up and deliver in the background so the response
+	// does not reveal whether the identifier exists
+	if !passwordlessQueue.start(w, r, func() { sendPasswordlessLogin(identifier, req.Method) }) {
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "If an account exists for this identifier, a sign-in code or link has been sent",
+	})
+}
+
+// @Summary      Complete passwordless login
+// @Description  Exchange a one-time code (with its identifier) or a magic link token for access & refresh tokens (also set as cookies). Accounts with two-factor authentication get an MFA challenge token instead. Wrong codes count towards the login lockout.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      PasswordlessVerifyRequest  true  "Code or magic link token"
+// @Success      200      {object}  AuthResponse
+// @Success      202      {object}  MFAChallengeResponse  "Two-factor authentication required"
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      401      {object}  ErrorResponse  "Invalid or expired code"
+// @Failure      403      {object}  ErrorResponse  "Account suspended"
+// @Failure      429      {object}  ErrorResponse  "Too many attempts"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/passwordless/verify [post]
+func VerifyPasswordlessLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req PasswordlessVerifyRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Identifier = strings.TrimSpace(strings.ToLower(req.Identifier))
+	req.Code = strings.TrimSpace(req.Code)
+	req.Token = strings.TrimSpace(req.Token)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var (
+		userID   primitive.ObjectID
+		verified string // the contact point the user just proved control of
+		err      error
+	)
+	switch {
+	case req.Token != "":
+		userID, err = utils.ConsumeUserToken(ctx, models.TokenPurposeMagicLink, req.Token)
+		if errors.Is(err, utils.ErrInvalidUserToken) {
+			utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired link")
+			return
+		}
+	case req.Identifier != "" && req.Code != "":
+		// Codes share the lockout with password login, so a locked
+		// identifier cannot be guessed through codes either
+		if loginLocked(ctx, w, r, req.Identifier, models.AuthMethodPasswordless) {
+			return
+		}
+
+		var otp *models.OTPCode
+		otp, err = utils.VerifyOTP(ctx, models.OTPPurposePasswordlessLogin, req.Identifier, req.Code)
+		if errors.Is(err, utils.ErrOTPTooManyAttempts) {
+			passwordlessCodeFailed(ctx, w, r, req.Identifier, "Too many attempts, please request a new code", http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, utils.ErrInvalidOTP) || (err == nil && otp.UserID == nil) {
+			passwordlessCodeFailed(ctx, w, r, req.Identifier, "Invalid or expired code", http.StatusUnauthorized)
+			return
+		}
+		if err == nil {
+			userID = *otp.UserID
+			verified = otp.Target
+		}
+	default:
+		utils.ApiError(w, http.StatusBadRequest, "Provide identifier and code, or token")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify login")
+		return
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired code")
+		return
+	}
+
+	// Codes only go to verified contacts; one that has changed since the
+	// code was sent no longer counts
+	if verified != "" && !contactVerified(&user, verified) {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired code")
+		return
+	}
+
+	finishLogin(ctx, w, r, &user, models.AuthMethodPasswordless)
+}
+
+// sendPasswordlessLogin delivers a code or magic link if the identifier belongs to an account
+func sendPasswordlessLogin(identifier, method string) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	filter := bson.M{
+		"$or": []bson.M{
+			{"email": identifier},
+			{"phone": identifier},
+		},
+	}
+
+	var user models.User
+	if err := users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return
+	}
+	byEmail := identifier == user.Email
+
+	// Nobody has proven control of an unverified contact, and it may not
+	// belong to whoever registered it, so it cannot be used to sign in
+	if !contactVerified(&user, identifier) {
+		return
+	}
+
+	subject, body := "Your sign-in code", ""
+	switch method {
+	case "link":
+		issuedAt, err := utils.LatestUserTokenIssuedAt(ctx, user.ID, models.TokenPurposeMagicLink)
+		if err != nil || time.Since(issuedAt) < cfg.Auth.OTPResendCooldown {
+			return
+		}
+		token, err := utils.IssueUserToken(ctx, user.ID, models.TokenPurposeMagicLink, cfg.Auth.MagicLinkTTL)
+		if err != nil {
+			log.Printf("Failed to issue magic link: %v", err)
+			return
+		}
+		link := cfg.Server.FrontendURL + "/login/magic?token=" + url.QueryEscape(token)
+		subject = "Your sign-in link"
+		body = fmt.Sprintf("Open this link to sign in: %s\n\nIt expires in %s and works once.", link, cfg.Auth.MagicLinkTTL)
+	default:
+		code, err := utils.IssueOTP(ctx, models.OTPPurposePasswordlessLogin, identifier, &user.ID)
+		if errors.Is(err, utils.ErrOTPCooldown) {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to issue login code: %v", err)
+			return
+		}
+		body = fmt.Sprintf("Your sign-in code is %s. It expires in %s. Do not share it with anyone.", code, cfg.Auth.OTPTTL)
+	}
+
+	var err error
+	if byEmail {
+		err = mailer.Send(ctx, mailer.Message{To: user.Email, Subject: subject, Body: body})
+	} else {
+		err = sms.Send(ctx, sms.Message{To: user.Phone, Body: body})
+	}
+	if err != nil {
+		log.Printf("Failed to deliver passwordless login: %v", err)
+	}
+}
+
+// contactVerified reports whether target is a verified email or phone of user
+func contactVerified(user *models.User, target string) bool {
+	switch target {
+	case "":
+		return false
+	case user.Email:
+		return user.EmailVerified
+	case user.Phone:
+		return user.PhoneVerified
+	}
+	return false
+}
+
+// passwordlessCodeFailed counts a wrong code for identifier toward the
+// login lockout and answers status with msg, or 429 once locked
+func passwordlessCodeFailed(ctx context.Context, w http.ResponseWriter, r *http.Request, identifier, msg string, status int) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	filter := bson.M{
+		"$or": []bson.M{
+			{"email": identifier},
+			{"phone": identifier},
+		},
+	}
+
+	var owner *models.User
+	var user models.User
+	if err := users.FindOne(ctx, filter).Decode(&user); err == nil {
+		owner = &user
+	}
+
+	if lockedUntil := countLoginFailure(ctx, r, identifier, models.AuthMethodPasswordless, owner); !lockedUntil.IsZero() {
+		tooManyLoginAttempts(w, lockedUntil)
+		return
+	}
+	utils.ApiError(w, status, msg)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/sms"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+// @Summary      Request phone verification code
+// @Description  Text a one-time code to the current user's phone number.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Phone already verified"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      429  {object}  ErrorResponse  "Too many requests"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/phone/verify/request [post]
+func RequestPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.PhoneVerified {
+		utils.ApiError(w, http.StatusBadRequest, "Phone number already verified")
+		return
+	}
+	if user.Phone == "" {
+		utils.ApiError(w, http.StatusBadRequest, "No phone number on this account")
+		return
+	}
+
+	code, err := utils.IssueOTP(ctx, models.OTPPurposePhoneVerification, user.Phone, &userID)
+	if errors.Is(err, utils.ErrOTPCooldown) {
+		utils.ApiError(w, http.StatusTooManyRequests, "Please wait before requesting another code")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to send verification code")
+		return
+	}
+
+	if err := sms.Send(ctx, sms.Message{To: user.Phone, Body: otpMessage(code)}); err != nil {
+		log.Printf("Failed to send phone verification code: %v", err)
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to send verification code")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Verification code sent",
+	})
+}
+
+// @Summary      Verify phone number
+// @Description  Confirm the current user's phone number with the code sent by SMS.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      VerifyPhoneRequest  true  "Verification code"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid or expired code"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      404      {object}  ErrorResponse  "User not found"
+// @Failure      429      {object}  ErrorResponse  "Too many attempts"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/phone/verify [post]
+func VerifyPhone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req VerifyPhoneRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Code == "" {
+		utils.ApiError(w, http.StatusBadRequest, "Missing code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// The code is bound to the phone number it was sent to
+	_, err = utils.VerifyOTP(ctx, models.OTPPurposePhoneVerification, user.Phone, req.Code)
+	if errors.Is(err, utils.ErrOTPTooManyAttempts) {
+		utils.ApiError(w, http.StatusTooManyRequests, "Too many attempts, please request a new code")
+		return
+	}
+	if errors.Is(err, utils.ErrInvalidOTP) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired code")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify phone number")
+		return
+	}
+
+	now := time.Now()
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID, "phone": user.Phone}, bson.M{
+		"$set": bson.M{
+			"phoneVerified":   true,
+			"phoneVerifiedAt": now,
+			"updatedAt":       now,
+		},
+	})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify phone number")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Phone number verified",
+	})
+}
+
+// otpMessage renders the text sent along with a one-time code
+func otpMessage(code string) string {
+	ttl := 5 * time.Minute
+	if cfg := config.AppConfig; cfg != nil {
+		ttl = cfg.Auth.OTPTTL
+	}
+	return fmt.Sprintf("Your verification code is %s. It expires in %s. Do not share it with anyone.", code, ttl)
+}
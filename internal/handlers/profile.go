@@ -1,8 +1,17 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MH-PAVEL/uni-backend-go/internal/database"
@@ -11,6 +20,8 @@ import (
 	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ProfileCompletionRequest struct {
@@ -40,6 +51,8 @@ type ProfileResponse struct {
 // @Success      200      {object}  ProfileResponse
 // @Failure      400      {object}  handlers.ErrorResponse  "Invalid request"
 // @Failure      401      {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404      {object}  handlers.ErrorResponse  "User not found"
 // @Failure      409      {object}  handlers.ErrorResponse  "NID already exists"
 // @Failure      500      {object}  handlers.ErrorResponse  "Internal error"
 // @Router       /api/v1/profile/complete [post]
@@ -50,13 +63,13 @@ func CompleteProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context (set by AuthMiddleware)
-	userIDStr := r.Context().Value(middleware.CtxUserID)
-	if userIDStr == nil {
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
 		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr.(string))
+	userID, err := claims.UserID()
 	if err != nil {
 		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
 		return
@@ -87,6 +100,19 @@ func CompleteProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Tests sent back with the id they were given keep it, so links to
+	// /api/v1/profile/language-tests/{id} stay valid
+	var current models.User
+	err = users.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"languageTests": 1}),
+	).Decode(&current)
+	if err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	req.LanguageTests = assignLanguageTestIDs(req.LanguageTests, current.LanguageTests)
+
 	// Update user profile
 	updateData := bson.M{
 		"$set": bson.M{
@@ -132,13 +158,13 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context (set by AuthMiddleware)
-	userIDStr := r.Context().Value(middleware.CtxUserID)
-	if userIDStr == nil {
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
 		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr.(string))
+	userID, err := claims.UserID()
 	if err != nil {
 		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
 		return
@@ -173,13 +199,13 @@ func GetProfileStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context (set by AuthMiddleware)
-	userIDStr := r.Context().Value(middleware.CtxUserID)
-	if userIDStr == nil {
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
 		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr.(string))
+	userID, err := claims.UserID()
 	if err != nil {
 		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
 		return
@@ -201,3 +227,338 @@ func GetProfileStatus(w http.ResponseWriter, r *http.Request) {
 		"hasProfile":        user.ProfileCompletion,
 	})
 }
+
+// ProfileFields are the profile fields PATCH /api/v1/profile accepts. Any
+// subset may be sent; null removes a field, nested objects are merged and
+// languageTests is replaced as a whole.
+type ProfileFields struct {
+	FullName             string                  `json:"fullName,omitempty"             example:"John Doe"`
+	Country              string                  `json:"country,omitempty"              example:"Bangladesh"`
+	Address              string                  `json:"address,omitempty"              example:"Dhaka, Bangladesh"`
+	NID                  string                  `json:"nid,omitempty"                  example:"1234567890123"`
+	PlanningMonthToStart string                  `json:"planningMonthToStart,omitempty" example:"January"`
+	PlanningYearToStart  string                  `json:"planningYearToStart,omitempty"  example:"2025"`
+	SSC                  *models.Education       `json:"ssc,omitempty"`
+	HSC                  *models.Education       `json:"hsc,omitempty"`
+	HigherEducation      *models.HigherEducation `json:"higherEducation,omitempty"`
+	LanguageTests        []models.LanguageTest   `json:"languageTests,omitempty"`
+}
+
+const (
+	maxProfileTextLength = 200
+	maxLanguageTests     = 20
+	maxGPA               = 5
+)
+
+var (
+	nidRegex          = regexp.MustCompile(`^[0-9A-Za-z]{5,20}$`)
+	yearRegex         = regexp.MustCompile(`^[0-9]{4}$`)
+	educationGroups   = []string{"science", "commerce", "arts"}
+	profileFieldNames = []string{
+		"fullName", "country", "address", "nid", "planningMonthToStart", "planningYearToStart",
+		"ssc", "hsc", "higherEducation", "languageTests",
+	}
+)
+
+// @Summary      Update user profile
+// @Description  Change some profile fields with a JSON Merge Patch (RFC 7386): only the fields sent change, null removes a field, ssc/hsc/higherEducation are merged field by field and languageTests is replaced. profileCompletion is recomputed: the profile is complete once full name, country, address and NID are set.
+// @Tags         profile
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      ProfileFields  true  "Fields to change"
+// @Success      200      {object}  models.User
+// @Failure      400      {object}  handlers.ErrorResponse  "Invalid field"
+// @Failure      401      {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404      {object}  handlers.ErrorResponse  "User not found"
+// @Failure      409      {object}  handlers.ErrorResponse  "NID already exists or profile changed concurrently"
+// @Failure      415      {object}  handlers.ErrorResponse  "Unsupported content type"
+// @Failure      500      {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile [patch]
+func PatchProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		utils.ApiError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		utils.ApiError(w, http.StatusBadRequest, "Request body must be a JSON object")
+		return
+	}
+	for name := range patch {
+		if !slices.Contains(profileFieldNames, name) {
+			utils.ApiError(w, http.StatusBadRequest, "Unknown field: "+name)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	profile, err := applyProfilePatch(&user, patch)
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	profile.LanguageTests = assignLanguageTestIDs(profile.LanguageTests, user.LanguageTests)
+
+	set := bson.M{}
+	unset := bson.M{}
+	for name := range patch {
+		value, err := profileFieldValue(profile, name)
+		if err != nil {
+			utils.ApiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if value == nil {
+			unset[name] = ""
+		} else {
+			set[name] = value
+		}
+	}
+
+	if nid, ok := set["nid"]; ok {
+		err := users.FindOne(ctx, bson.M{"nid": nid, "_id": bson.M{"$ne": userID}}).Err()
+		if err == nil {
+			utils.ApiError(w, http.StatusConflict, "NID already exists")
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+	}
+
+	// Completion follows the values actually stored: blank text is unset
+	// rather than kept
+	stored := func(name, current string) string {
+		if v, ok := set[name].(string); ok {
+			return v
+		}
+		if _, ok := unset[name]; ok {
+			return ""
+		}
+		return strings.TrimSpace(current)
+	}
+	set["profileCompletion"] = stored("fullName", profile.FullName) != "" &&
+		stored("country", profile.Country) != "" &&
+		stored("address", profile.Address) != "" &&
+		stored("nid", profile.NID) != ""
+	set["updatedAt"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// The patch was applied to the profile as read; fail rather than
+	// overwrite a change made in between
+	var updated models.User
+	err = users.FindOneAndUpdate(ctx,
+		bson.M{"_id": userID, "updatedAt": user.UpdatedAt},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusConflict, "Profile was changed at the same time, please retry")
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		utils.ApiError(w, http.StatusConflict, "NID already exists")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, updated)
+}
+
+// applyProfilePatch merges patch into the user's current profile and
+// returns the result
+func applyProfilePatch(user *models.User, patch map[string]any) (*ProfileFields, error) {
+	current, err := json.Marshal(ProfileFields{
+		FullName:             user.FullName,
+		Country:              user.Country,
+		Address:              user.Address,
+		NID:                  user.NID,
+		PlanningMonthToStart: user.PlanningMonthToStart,
+		PlanningYearToStart:  user.PlanningYearToStart,
+		SSC:                  user.SSC,
+		HSC:                  user.HSC,
+		HigherEducation:      user.HigherEducation,
+		LanguageTests:        user.LanguageTests,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var target map[string]any
+	if err := json.Unmarshal(current, &target); err != nil {
+		return nil, err
+	}
+
+	merged, err := json.Marshal(utils.MergePatch(target, patch))
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	var profile ProfileFields
+	if err := dec.Decode(&profile); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("Invalid value for %s", typeErr.Field)
+		}
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, fmt.Errorf("Unknown field: %s", strings.Trim(field, `"`))
+		}
+		return nil, fmt.Errorf("Invalid request")
+	}
+	return &profile, nil
+}
+
+// profileFieldValue validates a patched field and returns the value to
+// store, or nil if the field was removed
+func profileFieldValue(p *ProfileFields, name string) (any, error) {
+	text := func(label, v string) (any, error) {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, nil
+		}
+		if len(v) > maxProfileTextLength {
+			return nil, fmt.Errorf("%s is too long", label)
+		}
+		return v, nil
+	}
+
+	switch name {
+	case "fullName":
+		return text("Full name", p.FullName)
+	case "country":
+		return text("Country", p.Country)
+	case "address":
+		return text("Address", p.Address)
+	case "nid":
+		if p.NID == "" {
+			return nil, nil
+		}
+		if !nidRegex.MatchString(p.NID) {
+			return nil, fmt.Errorf("NID must be 5 to 20 letters or digits")
+		}
+		return p.NID, nil
+	case "planningMonthToStart":
+		if p.PlanningMonthToStart == "" {
+			return nil, nil
+		}
+		for m := time.January; m <= time.December; m++ {
+			if strings.EqualFold(p.PlanningMonthToStart, m.String()) {
+				return m.String(), nil
+			}
+		}
+		return nil, fmt.Errorf("planningMonthToStart must be a month name")
+	case "planningYearToStart":
+		if p.PlanningYearToStart == "" {
+			return nil, nil
+		}
+		year, _ := strconv.Atoi(p.PlanningYearToStart)
+		now := time.Now().Year()
+		if !yearRegex.MatchString(p.PlanningYearToStart) || year < now || year > now+10 {
+			return nil, fmt.Errorf("planningYearToStart must be a year from %d to %d", now, now+10)
+		}
+		return p.PlanningYearToStart, nil
+	case "ssc", "hsc":
+		edu := p.SSC
+		if name == "hsc" {
+			edu = p.HSC
+		}
+		if edu == nil {
+			return nil, nil
+		}
+		if edu.Background != "" {
+			edu.Background = strings.ToLower(edu.Background)
+			if !slices.Contains(educationGroups, edu.Background) {
+				return nil, fmt.Errorf("%s.background must be science, commerce or arts", name)
+			}
+		}
+		if edu.GPA < 0 || edu.GPA > maxGPA {
+			return nil, fmt.Errorf("%s.gpa must be between 0 and %d", name, maxGPA)
+		}
+		return edu, nil
+	case "higherEducation":
+		if p.HigherEducation == nil {
+			return nil, nil
+		}
+		if p.HigherEducation.CGPA < 0 || p.HigherEducation.CGPA > maxGPA {
+			return nil, fmt.Errorf("higherEducation.cgpa must be between 0 and %d", maxGPA)
+		}
+		return p.HigherEducation, nil
+	case "languageTests":
+		if len(p.LanguageTests) == 0 {
+			return nil, nil
+		}
+		if len(p.LanguageTests) > maxLanguageTests {
+			return nil, fmt.Errorf("At most %d language tests are allowed", maxLanguageTests)
+		}
+		for i, t := range p.LanguageTests {
+			if err := validateLanguageTest(t); err != nil {
+				return nil, fmt.Errorf("languageTests[%d]: %w", i, err)
+			}
+		}
+		return p.LanguageTests, nil
+	}
+	return nil, fmt.Errorf("Unknown field: %s", name)
+}
+
+func validateLanguageTest(t models.LanguageTest) error {
+	if strings.TrimSpace(t.TestType) == "" || strings.TrimSpace(t.Score) == "" {
+		return errors.New("testType and score are required")
+	}
+	if t.TestYear != "" && !yearRegex.MatchString(t.TestYear) {
+		return errors.New("testYear must be a year")
+	}
+	return nil
+}
+
+// assignLanguageTestIDs gives every test a stable id. Tests keep an id that
+// belongs to one of the current tests; anything else gets a new one.
+func assignLanguageTestIDs(tests, current []models.LanguageTest) []models.LanguageTest {
+	for i := range tests {
+		known := !tests[i].ID.IsZero() && slices.ContainsFunc(current, func(c models.LanguageTest) bool {
+			return c.ID == tests[i].ID
+		})
+		if !known {
+			tests[i].ID = primitive.NewObjectID()
+		}
+	}
+	return tests
+}
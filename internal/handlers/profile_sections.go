@@ -0,0 +1,678 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// educationFields maps the education levels in the URL to profile fields
+var educationFields = map[string]string{
+	"ssc":    "ssc",
+	"hsc":    "hsc",
+	"higher": "higherEducation",
+}
+
+var errTooManyLanguageTests = errors.New("too many language tests")
+
+// @Summary      Get education
+// @Description  Get one education entry of the current user's profile.
+// @Tags         profile
+// @Produce      json
+// @Security     BearerAuth
+// @Param        level  path      string  true  "Education level"  Enums(ssc, hsc, higher)
+// @Success      200    {object}  models.Education  "models.HigherEducation for higher"
+// @Failure      401    {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      404    {object}  handlers.ErrorResponse  "Unknown level or not set"
+// @Failure      500    {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/education/{level} [get]
+func GetEducation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	field, ok := educationFields[r.PathValue("level")]
+	if !ok {
+		utils.ApiError(w, http.StatusNotFound, "Unknown education level")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	err = users.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{field: 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load profile")
+		return
+	}
+
+	var education any
+	switch field {
+	case "ssc":
+		if user.SSC != nil {
+			education = user.SSC
+		}
+	case "hsc":
+		if user.HSC != nil {
+			education = user.HSC
+		}
+	default:
+		if user.HigherEducation != nil {
+			education = user.HigherEducation
+		}
+	}
+	if education == nil {
+		utils.ApiError(w, http.StatusNotFound, "Education not set")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, education)
+}
+
+// @Summary      Save education
+// @Description  Create or replace one education entry of the current user's profile. ssc and hsc take models.Education; higher takes models.HigherEducation.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        level    path      string            true  "Education level"  Enums(ssc, hsc, higher)
+// @Param        payload  body      models.Education  true  "Education"
+// @Success      200      {object}  models.Education  "models.HigherEducation for higher"
+// @Failure      400      {object}  handlers.ErrorResponse  "Invalid field"
+// @Failure      401      {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404      {object}  handlers.ErrorResponse  "Unknown level or user not found"
+// @Failure      500      {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/education/{level} [put]
+func PutEducation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	field, ok := educationFields[r.PathValue("level")]
+	if !ok {
+		utils.ApiError(w, http.StatusNotFound, "Unknown education level")
+		return
+	}
+
+	// Validated the same way as through PATCH /api/v1/profile
+	var profile ProfileFields
+	var body any
+	switch field {
+	case "ssc":
+		profile.SSC = &models.Education{}
+		body = profile.SSC
+	case "hsc":
+		profile.HSC = &models.Education{}
+		body = profile.HSC
+	default:
+		profile.HigherEducation = &models.HigherEducation{}
+		body = profile.HigherEducation
+	}
+	if err := utils.SafeDecodeJSON(r, body); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	education, err := profileFieldValue(&profile, field)
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = saveEducation(ctx, userID, field, education)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to save education")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, education)
+}
+
+// @Summary      Delete education
+// @Description  Remove one education entry from the current user's profile. Removing one that is not set is not an error.
+// @Tags         profile
+// @Produce      json
+// @Security     BearerAuth
+// @Param        level  path      string  true  "Education level"  Enums(ssc, hsc, higher)
+// @Success      200    {object}  MessageResponse
+// @Failure      401    {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403    {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404    {object}  handlers.ErrorResponse  "Unknown level or user not found"
+// @Failure      500    {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/education/{level} [delete]
+func DeleteEducation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	field, ok := educationFields[r.PathValue("level")]
+	if !ok {
+		utils.ApiError(w, http.StatusNotFound, "Unknown education level")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = saveEducation(ctx, userID, field, nil)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to delete education")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "Education deleted"})
+}
+
+// @Summary      List language tests
+// @Description  List the language tests on the current user's profile.
+// @Tags         profile
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.LanguageTest
+// @Failure      401  {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  handlers.ErrorResponse  "User not found"
+// @Failure      500  {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/language-tests [get]
+func ListLanguageTests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tests, err := loadLanguageTests(ctx, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load language tests")
+		return
+	}
+	if tests == nil {
+		tests = []models.LanguageTest{}
+	}
+
+	utils.ApiResponse(w, http.StatusOK, tests)
+}
+
+// @Summary      Add language test
+// @Description  Add a language test to the current user's profile. The response carries the id used to change or remove it.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      models.LanguageTest  true  "Language test"
+// @Success      201      {object}  models.LanguageTest
+// @Failure      400      {object}  handlers.ErrorResponse  "Invalid field or too many tests"
+// @Failure      401      {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404      {object}  handlers.ErrorResponse  "User not found"
+// @Failure      500      {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/language-tests [post]
+func CreateLanguageTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var test models.LanguageTest
+	if err := utils.SafeDecodeJSON(r, &test); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := validateLanguageTest(test); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	test.ID = primitive.NewObjectID()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = addLanguageTest(ctx, userID, test)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if errors.Is(err, errTooManyLanguageTests) {
+		utils.ApiError(w, http.StatusBadRequest, "Too many language tests")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to save language test")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusCreated, test)
+}
+
+// @Summary      Get language test
+// @Description  Get one language test from the current user's profile.
+// @Tags         profile
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Language test ID"
+// @Success      200  {object}  models.LanguageTest
+// @Failure      400  {object}  handlers.ErrorResponse  "Invalid id"
+// @Failure      401  {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  handlers.ErrorResponse  "Language test not found"
+// @Failure      500  {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/language-tests/{id} [get]
+func GetLanguageTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	testID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tests, err := loadLanguageTests(ctx, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load language test")
+		return
+	}
+
+	i := languageTestIndex(tests, testID)
+	if i < 0 {
+		utils.ApiError(w, http.StatusNotFound, "Language test not found")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, tests[i])
+}
+
+// @Summary      Update language test
+// @Description  Replace one language test on the current user's profile.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string               true  "Language test ID"
+// @Param        payload  body      models.LanguageTest  true  "Language test"
+// @Success      200      {object}  models.LanguageTest
+// @Failure      400      {object}  handlers.ErrorResponse  "Invalid id or field"
+// @Failure      401      {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404      {object}  handlers.ErrorResponse  "Language test not found"
+// @Failure      500      {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/language-tests/{id} [put]
+func UpdateLanguageTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	testID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var test models.LanguageTest
+	if err := utils.SafeDecodeJSON(r, &test); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := validateLanguageTest(test); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	test.ID = testID
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = replaceLanguageTest(ctx, userID, test)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "Language test not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to save language test")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, test)
+}
+
+// @Summary      Delete language test
+// @Description  Remove one language test from the current user's profile.
+// @Tags         profile
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Language test ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  handlers.ErrorResponse  "Invalid id"
+// @Failure      401  {object}  handlers.ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  handlers.ErrorResponse  "Email address not verified"
+// @Failure      404  {object}  handlers.ErrorResponse  "Language test not found"
+// @Failure      500  {object}  handlers.ErrorResponse  "Internal error"
+// @Router       /api/v1/profile/language-tests/{id} [delete]
+func DeleteLanguageTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	testID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = removeLanguageTest(ctx, userID, testID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		utils.ApiError(w, http.StatusNotFound, "Language test not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to delete language test")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{Message: "Language test deleted"})
+}
+
+// saveEducation sets one education entry, or removes it when value is nil.
+// The entry is replaced as a whole, so the last write wins.
+func saveEducation(ctx context.Context, userID primitive.ObjectID, field string, value any) error {
+	set := bson.M{"updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if value != nil {
+		set[field] = value
+	} else {
+		update["$unset"] = bson.M{field: ""}
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// loadLanguageTests returns the user's language tests. Tests saved before
+// they had ids are given one here.
+func loadLanguageTests(ctx context.Context, userID primitive.ObjectID) ([]models.LanguageTest, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	// Matched on the tests as stored, so a test added or removed meanwhile
+	// is not overwritten; the tests are then read again and retried.
+	for range 3 {
+		tests, stored, err := findLanguageTests(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(tests, func(t models.LanguageTest) bool { return t.ID.IsZero() }) {
+			return tests, nil
+		}
+
+		tests = assignLanguageTestIDs(tests, tests)
+		res, err := users.UpdateOne(ctx,
+			bson.M{"_id": userID, "languageTests": stored},
+			bson.M{"$set": bson.M{"languageTests": tests, "updatedAt": time.Now()}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount > 0 {
+			return tests, nil
+		}
+	}
+	return nil, errors.New("language tests kept changing")
+}
+
+// findLanguageTests reads the user's language tests, both decoded and as
+// stored
+func findLanguageTests(ctx context.Context, userID primitive.ObjectID) ([]models.LanguageTest, bson.RawValue, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var doc struct {
+		LanguageTests bson.RawValue `bson:"languageTests"`
+	}
+	err := users.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"languageTests": 1}),
+	).Decode(&doc)
+	if err != nil {
+		return nil, bson.RawValue{}, err
+	}
+
+	var tests []models.LanguageTest
+	if doc.LanguageTests.Type == bson.TypeArray {
+		if err := doc.LanguageTests.Unmarshal(&tests); err != nil {
+			return nil, bson.RawValue{}, err
+		}
+	}
+	return tests, doc.LanguageTests, nil
+}
+
+// addLanguageTest appends test to the user's language tests unless they
+// already hold maxLanguageTests. Like the other language test writes it
+// touches only its own test, so concurrent writes do not undo each other.
+func addLanguageTest(ctx context.Context, userID primitive.ObjectID, test models.LanguageTest) error {
+	current := bson.M{"$ifNull": bson.A{"$languageTests", bson.A{}}}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx,
+		bson.M{
+			"_id":   userID,
+			"$expr": bson.M{"$lt": bson.A{bson.M{"$size": current}, maxLanguageTests}},
+		},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"languageTests": bson.M{"$concatArrays": bson.A{current, bson.A{bson.M{"$literal": test}}}},
+				"updatedAt":     time.Now(),
+			}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	n, err := users.CountDocuments(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return errTooManyLanguageTests
+}
+
+// replaceLanguageTest replaces the test with test's id, returning
+// mongo.ErrNoDocuments when the user has no such test
+func replaceLanguageTest(ctx context.Context, userID primitive.ObjectID, test models.LanguageTest) error {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx,
+		bson.M{"_id": userID, "languageTests._id": test.ID},
+		bson.M{"$set": bson.M{"languageTests.$": test, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// removeLanguageTest removes the test with the given id, returning
+// mongo.ErrNoDocuments when the user has no such test
+func removeLanguageTest(ctx context.Context, userID, testID primitive.ObjectID) error {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.UpdateOne(ctx,
+		bson.M{"_id": userID, "languageTests._id": testID},
+		bson.M{
+			"$pull": bson.M{"languageTests": bson.M{"_id": testID}},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func languageTestIndex(tests []models.LanguageTest, id primitive.ObjectID) int {
+	return slices.IndexFunc(tests, func(t models.LanguageTest) bool { return t.ID == id })
+}
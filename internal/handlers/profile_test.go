@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/testutil"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// serveAs runs handler as if AuthMiddleware had authenticated userID
+func serveAs(handler http.HandlerFunc, userID primitive.ObjectID, method, target, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	claims := &utils.AccessClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID.Hex()}}
+	r = r.WithContext(context.WithValue(r.Context(), middleware.CtxClaims, claims))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestPatchProfileCompletion(t *testing.T) {
+	testutil.Mongo(t)
+
+	user := insertTestUser(t, models.User{
+		Email:             "profile@example.com",
+		EmailVerified:     true,
+		FullName:          "Jane Doe",
+		Country:           "Bangladesh",
+		Address:           "Dhaka",
+		NID:               "1234567890",
+		ProfileCompletion: true,
+	}, "")
+
+	patch := func(body string) models.User {
+		t.Helper()
+		w := serveAs(PatchProfile, user.ID, http.MethodPatch, "/api/v1/profile", "application/merge-patch+json", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PATCH %s: status = %d, body %s", body, w.Code, w.Body)
+		}
+		return loadTestUser(t, user.ID)
+	}
+
+	// A blank name is removed, so the profile is no longer complete
+	stored := patch(`{"fullName":"   "}`)
+	if stored.FullName != "" || stored.ProfileCompletion {
+		t.Fatalf("fullName = %q, profileCompletion = %t", stored.FullName, stored.ProfileCompletion)
+	}
+
+	stored = patch(`{"fullName":"  Jane Doe  "}`)
+	if stored.FullName != "Jane Doe" || !stored.ProfileCompletion {
+		t.Fatalf("fullName = %q, profileCompletion = %t", stored.FullName, stored.ProfileCompletion)
+	}
+
+	stored = patch(`{"address":null}`)
+	if stored.Address != "" || stored.ProfileCompletion {
+		t.Fatalf("address = %q, profileCompletion = %t", stored.Address, stored.ProfileCompletion)
+	}
+}
+
+func TestCompleteProfileKeepsLanguageTestIDs(t *testing.T) {
+	testutil.Mongo(t)
+
+	kept := models.LanguageTest{ID: primitive.NewObjectID(), TestType: "IELTS", Score: "7.5", TestYear: "2024"}
+	user := insertTestUser(t, models.User{
+		Email:         "wizard@example.com",
+		EmailVerified: true,
+		LanguageTests: []models.LanguageTest{kept},
+	}, "")
+
+	body := `{"fullName":"Jane Doe","country":"Bangladesh","address":"Dhaka","nid":"1234567890",` +
+		`"languageTests":[{"id":"` + kept.ID.Hex() + `","testType":"IELTS","score":"8.0","testYear":"2024"},` +
+		`{"testType":"TOEFL","score":"100","testYear":"2023"}]}`
+	w := serveAs(CompleteProfile, user.ID, http.MethodPost, "/api/v1/profile/complete", "application/json", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	stored := loadTestUser(t, user.ID).LanguageTests
+	if len(stored) != 2 {
+		t.Fatalf("%d language tests, want 2", len(stored))
+	}
+	if stored[0].ID != kept.ID || stored[0].Score != "8.0" {
+		t.Fatalf("first test = %+v, want id %s kept", stored[0], kept.ID.Hex())
+	}
+	if stored[1].ID.IsZero() || stored[1].ID == kept.ID {
+		t.Fatalf("second test id = %s, want a new one", stored[1].ID.Hex())
+	}
+}
+
+func TestSaveEducation(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+
+	user := insertTestUser(t, models.User{Email: "education@example.com", EmailVerified: true}, "")
+
+	if err := saveEducation(ctx, user.ID, "ssc", &models.Education{GPA: 5}); err != nil {
+		t.Fatalf("saveEducation: %v", err)
+	}
+	if err := saveEducation(ctx, user.ID, "hsc", &models.Education{GPA: 4}); err != nil {
+		t.Fatalf("saveEducation: %v", err)
+	}
+	stored := loadTestUser(t, user.ID)
+	if stored.SSC == nil || stored.SSC.GPA != 5 || stored.HSC == nil || stored.HSC.GPA != 4 {
+		t.Fatalf("ssc = %+v, hsc = %+v", stored.SSC, stored.HSC)
+	}
+
+	// Removing one entry leaves the others alone
+	if err := saveEducation(ctx, user.ID, "ssc", nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	stored = loadTestUser(t, user.ID)
+	if stored.SSC != nil || stored.HSC == nil {
+		t.Fatalf("ssc = %+v, hsc = %+v after delete", stored.SSC, stored.HSC)
+	}
+
+	if err := saveEducation(ctx, primitive.NewObjectID(), "ssc", nil); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("unknown user: err = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+}
+
+func TestLanguageTestWrites(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+
+	user := insertTestUser(t, models.User{Email: "language@example.com", EmailVerified: true}, "")
+
+	first := models.LanguageTest{ID: primitive.NewObjectID(), TestType: "IELTS", Score: "7.5"}
+	second := models.LanguageTest{ID: primitive.NewObjectID(), TestType: "TOEFL", Score: "$100"}
+	for _, test := range []models.LanguageTest{first, second} {
+		if err := addLanguageTest(ctx, user.ID, test); err != nil {
+			t.Fatalf("addLanguageTest: %v", err)
+		}
+	}
+
+	// Each write changes only its own test
+	first.Score = "8.0"
+	if err := replaceLanguageTest(ctx, user.ID, first); err != nil {
+		t.Fatalf("replaceLanguageTest: %v", err)
+	}
+	if err := removeLanguageTest(ctx, user.ID, second.ID); err != nil {
+		t.Fatalf("removeLanguageTest: %v", err)
+	}
+	stored := loadTestUser(t, user.ID).LanguageTests
+	if len(stored) != 1 || stored[0] != first {
+		t.Fatalf("stored = %+v, want [%+v]", stored, first)
+	}
+
+	if err := replaceLanguageTest(ctx, user.ID, second); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("replace removed test: err = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+	if err := removeLanguageTest(ctx, user.ID, second.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("remove removed test: err = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+	if err := addLanguageTest(ctx, primitive.NewObjectID(), second); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Fatalf("unknown user: err = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+
+	for len(stored) < maxLanguageTests {
+		test := models.LanguageTest{ID: primitive.NewObjectID(), TestType: "PTE", Score: "65"}
+		if err := addLanguageTest(ctx, user.ID, test); err != nil {
+			t.Fatalf("addLanguageTest: %v", err)
+		}
+		stored = append(stored, test)
+	}
+	if err := addLanguageTest(ctx, user.ID, second); !errors.Is(err, errTooManyLanguageTests) {
+		t.Fatalf("full: err = %v, want %v", err, errTooManyLanguageTests)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionResponse is a signed-in device as shown to its owner
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current" example:"true"`
+}
+
+// RevokeSessionsResponse reports how many sessions were ended
+type RevokeSessionsResponse struct {
+	Message string `json:"message" example:"Other sessions revoked"`
+	Revoked int64  `json:"revoked" example:"2"`
+}
+
+// @Summary      List sessions
+// @Description  List the devices the current user is signed in on.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   SessionResponse
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/sessions [get]
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+	currentID := claims.SessionID
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := utils.ListSessions(ctx, userID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load sessions")
+		return
+	}
+
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{
+			Session: s,
+			Current: s.ID.Hex() == currentID,
+		})
+	}
+
+	utils.ApiResponse(w, http.StatusOK, resp)
+}
+
+// @Summary      Revoke a session
+// @Description  Sign the current user out of one device. Revoking the current session also clears auth cookies.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Session ID"
+// @Success      200  {object}  LogoutResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid session id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "Session not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/sessions/{id} [delete]
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid session id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = utils.RevokeSession(ctx, userID, sessionID)
+	if errors.Is(err, utils.ErrSessionNotFound) {
+		utils.ApiError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	if claims.SessionID == sessionID.Hex() {
+		utils.ClearAuthCookies(w)
+	}
+
+	utils.ApiResponse(w, http.StatusOK, LogoutResponse{
+		Message: "Session revoked",
+	})
+}
+
+// @Summary      Log out everywhere else
+// @Description  Revoke every session of the current user except the one making the request.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  RevokeSessionsResponse
+// @Failure      400  {object}  ErrorResponse  "Current session unknown"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/sessions [delete]
+func RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	// Tokens issued before sessions existed carry no session id
+	sid := claims.SessionID
+	currentID, err := primitive.ObjectIDFromHex(sid)
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Current session unknown, please log in again")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := utils.RevokeOtherSessions(ctx, userID, currentID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, RevokeSessionsResponse{
+		Message: "Other sessions revoked",
+		Revoked: revoked,
+	})
+}
@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"github.com/MH-PAVEL/uni-backend-go/internal/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PasskeyRegistrationRequest struct {
+	Name       string                        `json:"name" example:"My phone"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+type PasskeyLoginBeginRequest struct {
+	Identifier string `json:"identifier,omitempty" example:"F2HbU@example.com"` // optional email or phone
+}
+
+// @Summary      Begin passkey registration
+// @Description  Create options for navigator.credentials.create(). Binary fields are base64url encoded.
+// @Tags         webauthn
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  webauthn.CreationOptions
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/register/begin [post]
+func BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	existing, err := passkeyDescriptors(ctx, userID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load passkeys")
+		return
+	}
+
+	challenge, err := utils.BeginWebAuthnCeremony(ctx, models.WebAuthnCeremonyRegistration, &userID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to start registration")
+		return
+	}
+
+	name := user.Email
+	if name == "" {
+		name = user.Phone
+	}
+	displayName := strings.TrimSpace(user.FullName)
+	if displayName == "" {
+		displayName = name
+	}
+
+	rp := utils.WebAuthnRP()
+	utils.ApiResponse(w, http.StatusOK, rp.NewCreationOptions(challenge, userID[:], name, displayName, existing))
+}
+
+// @Summary      Finish passkey registration
+// @Description  Verify the credential returned by navigator.credentials.create() and save it as a passkey.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        payload  body      PasskeyRegistrationRequest  true  "Passkey name and credential"
+// @Success      201      {object}  models.WebAuthnCredential
+// @Failure      400      {object}  ErrorResponse  "Invalid or expired registration"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      409      {object}  ErrorResponse  "Passkey already registered"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/register/finish [post]
+func FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	var req PasskeyRegistrationRequest
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = "Passkey"
+	}
+	if len(req.Name) > 64 {
+		utils.ApiError(w, http.StatusBadRequest, "Name must be at most 64 characters")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	challenge, ceremony, err := utils.TakeWebAuthnChallenge(ctx, models.WebAuthnCeremonyRegistration, req.Credential.Response.ClientDataJSON)
+	if errors.Is(err, utils.ErrWebAuthnChallenge) || (err == nil && (ceremony.UserID == nil || *ceremony.UserID != userID)) {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid or expired registration, please try again")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify passkey")
+		return
+	}
+
+	cred, err := utils.WebAuthnRP().VerifyRegistration(challenge, &req.Credential)
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Passkey could not be verified")
+		return
+	}
+
+	doc := models.WebAuthnCredential{
+		ID:           primitive.NewObjectID(),
+		UserID:       userID,
+		CredentialID: webauthn.URLEncodedBase64(cred.ID).String(),
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+		AAGUID:       cred.AAGUID,
+		Transports:   cred.Transports,
+		Name:         req.Name,
+		CreatedAt:    time.Now(),
+	}
+
+	credentials := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+	if _, err := credentials.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			utils.ApiError(w, http.StatusConflict, "Passkey already registered")
+			return
+		}
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to save passkey")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusCreated, doc)
+}
+
+// @Summary      Begin passkey login
+// @Description  Create options for navigator.credentials.get(). With an identifier the account's passkeys are listed; without one the browser offers any discoverable passkey. Unknown identifiers get a stable list of made-up passkeys, so the response does not reveal whether the account exists.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      PasskeyLoginBeginRequest  false  "Optional identifier"
+// @Success      200      {object}  webauthn.RequestOptions
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/login/begin [post]
+func BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req PasskeyLoginBeginRequest
+	if r.ContentLength != 0 {
+		if err := utils.SafeDecodeJSON(r, &req); err != nil {
+			utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+	}
+	identifier := strings.TrimSpace(strings.ToLower(req.Identifier))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var allow []webauthn.CredentialDescriptor
+	if identifier != "" {
+		users := database.GetCollection(database.DbName(), database.UsersCollection)
+		filter := bson.M{
+			"$or": []bson.M{
+				{"email": identifier},
+				{"phone": identifier},
+			},
+		}
+
+		var user models.User
+		err := users.FindOne(ctx, filter).Decode(&user)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.ApiError(w, http.StatusInternalServerError, "Failed to start login")
+			return
+		}
+		if err == nil {
+			allow, err = passkeyDescriptors(ctx, user.ID)
+			if err != nil {
+				utils.ApiError(w, http.StatusInternalServerError, "Failed to start login")
+				return
+			}
+		}
+		// Unknown identifiers and accounts without passkeys get made-up
+		// passkeys, the same ones every time, so an empty list does not
+		// give away which accounts exist
+		if len(allow) == 0 {
+			allow = decoyPasskeyDescriptors(identifier)
+		}
+	}
+
+	challenge, err := utils.BeginWebAuthnCeremony(ctx, models.WebAuthnCeremonyLogin, nil)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	rp := utils.WebAuthnRP()
+	utils.ApiResponse(w, http.StatusOK, rp.NewRequestOptions(challenge, allow))
+}
+
+// @Summary      Finish passkey login
+// @Description  Verify the assertion returned by navigator.credentials.get() and return access & refresh tokens (also set as cookies). Passkeys require user verification, so no further MFA step is needed.
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      webauthn.AssertionResponse  true  "Assertion"
+// @Success      200      {object}  AuthResponse
+// @Failure      400      {object}  ErrorResponse  "Invalid request"
+// @Failure      401      {object}  ErrorResponse  "Passkey could not be verified"
+// @Failure      403      {object}  ErrorResponse  "Account suspended"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/login/finish [post]
+func FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var req webauthn.AssertionResponse
+	if err := utils.SafeDecodeJSON(r, &req); err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if len(req.RawID) == 0 {
+		utils.ApiError(w, http.StatusBadRequest, "Missing credential id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	challenge, _, err := utils.TakeWebAuthnChallenge(ctx, models.WebAuthnCeremonyLogin, req.Response.ClientDataJSON)
+	if errors.Is(err, utils.ErrWebAuthnChallenge) {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid or expired login, please try again")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify passkey")
+		return
+	}
+
+	credentials := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+
+	var cred models.WebAuthnCredential
+	if err := credentials.FindOne(ctx, bson.M{"credentialId": req.RawID.String()}).Decode(&cred); err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+	// Discoverable credentials report the user handle set at registration
+	if len(req.Response.UserHandle) > 0 && string(req.Response.UserHandle) != string(cred.UserID[:]) {
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	signCount, err := utils.WebAuthnRP().VerifyAssertion(challenge, &req, cred.PublicKey, cred.SignCount)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		_ = utils.RecordSecurityEvent(ctx, models.SecurityEvent{
+			Type:      models.SecurityEventPasskeyCloned,
+			UserID:    &cred.UserID,
+			IP:        utils.ClientIP(r),
+			UserAgent: r.UserAgent(),
+			Details:   "passkey " + cred.ID.Hex() + " reported a signature counter that did not increase",
+		})
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	// Only advance the counter if no concurrent login already did
+	now := time.Now()
+	res, err := credentials.UpdateOne(ctx,
+		bson.M{"_id": cred.ID, "signCount": cred.SignCount},
+		bson.M{"$set": bson.M{"signCount": signCount, "lastUsedAt": now}},
+	)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to verify passkey")
+		return
+	}
+	if res.MatchedCount == 0 {
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var user models.User
+	if err := users.FindOne(ctx, bson.M{"_id": cred.UserID}).Decode(&user); err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Passkey could not be verified")
+		return
+	}
+
+	issueLoginTokens(ctx, w, r, &user, models.AuthMethodPasskey)
+}
+
+// @Summary      List passkeys
+// @Description  List the passkeys registered to the current user.
+// @Tags         webauthn
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.WebAuthnCredential
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/credentials [get]
+func ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	creds, err := findPasskeys(ctx, userID)
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to load passkeys")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, creds)
+}
+
+// @Summary      Delete a passkey
+// @Description  Remove one of the current user's passkeys.
+// @Tags         webauthn
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Passkey ID"
+// @Success      200  {object}  MessageResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid passkey id"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "Passkey not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /api/v1/auth/webauthn/credentials/{id} [delete]
+func DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ApiError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	claims, ok := middleware.GetClaims(r.Context())
+	if !ok {
+		utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+		return
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+		return
+	}
+
+	credID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		utils.ApiError(w, http.StatusBadRequest, "Invalid passkey id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	credentials := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+	res, err := credentials.DeleteOne(ctx, bson.M{"_id": credID, "userId": userID})
+	if err != nil {
+		utils.ApiError(w, http.StatusInternalServerError, "Failed to delete passkey")
+		return
+	}
+	if res.DeletedCount == 0 {
+		utils.ApiError(w, http.StatusNotFound, "Passkey not found")
+		return
+	}
+
+	utils.ApiResponse(w, http.StatusOK, MessageResponse{
+		Message: "Passkey deleted",
+	})
+}
+
+// findPasskeys returns a user's passkeys, oldest first
+func findPasskeys(ctx context.Context, userID primitive.ObjectID) ([]models.WebAuthnCredential, error) {
+	credentials := database.GetCollection(database.DbName(), database.WebAuthnCredentialsCollection)
+
+	cursor, err := credentials.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	creds := []models.WebAuthnCredential{}
+	if err := cursor.All(ctx, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// decoyPasskeyDescriptors derives one or two credential descriptors from
+// identifier, keyed by the app secret so they cannot be told from real ones
+func decoyPasskeyDescriptors(identifier string) []webauthn.CredentialDescriptor {
+	secret := ""
+	if cfg := config.AppConfig; cfg != nil {
+		secret = cfg.Auth.JWTSecret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("passkey-decoy:" + identifier))
+	seed := mac.Sum(nil)
+
+	descriptors := make([]webauthn.CredentialDescriptor, 1+int(seed[0]%2))
+	for i := range descriptors {
+		mac.Reset()
+		mac.Write(seed)
+		mac.Write([]byte{byte(i)})
+		descriptors[i] = webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         mac.Sum(nil),
+			Transports: []string{"internal", "hybrid"},
+		}
+	}
+	return descriptors
+}
+
+// passkeyDescriptors lists a user's passkeys in the form WebAuthn options use
+func passkeyDescriptors(ctx context.Context, userID primitive.ObjectID) ([]webauthn.CredentialDescriptor, error) {
+	creds, err := findPasskeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			log.Printf("Skipping passkey %s with malformed id: %v", c.ID.Hex(), err)
+			continue
+		}
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         id,
+			Transports: c.Transports,
+		})
+	}
+	return descriptors, nil
+}
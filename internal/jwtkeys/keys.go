@@ -0,0 +1,226 @@
+// Package jwtkeys holds the keys used to sign and verify access tokens.
+// Tokens carry a kid header naming their key, so keys can be rotated: the
+// current key signs, older keys stay valid for verification until the
+// tokens they signed have expired.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA key accepted
+const minRSABits = 2048
+
+var ErrNotConfigured = errors.New("jwt signing keys not configured")
+
+// Key is one signing or verification key
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	signer any // private key or HMAC secret; nil for verification-only keys
+	public any // public key or HMAC secret
+}
+
+// KeySet is the current signing key plus every key accepted for verification
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []*Key
+	// legacy verifies tokens issued before kid headers were added
+	legacy *Key
+}
+
+// Default is the key set used by Sign and Keyfunc. It is set by Init.
+var Default *KeySet
+
+// Init builds the key set from the auth configuration
+func Init(cfg config.AuthConfig) error {
+	ks, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	Default = ks
+	return nil
+}
+
+// New builds a key set from the auth configuration
+func New(cfg config.AuthConfig) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+
+	alg := strings.TrimSpace(cfg.JWTAlgorithm)
+	if alg == "" {
+		alg = AlgHS256
+	}
+
+	if alg == AlgHS256 || cfg.JWTAcceptHS256 {
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		hmacKey := newHMACKey(cfg.JWTSecret)
+		ks.add(hmacKey)
+		ks.legacy = hmacKey
+	}
+
+	switch alg {
+	case AlgHS256:
+		ks.signing = ks.legacy
+	case AlgRS256, AlgEdDSA:
+		if cfg.JWTPrivateKeyFile == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
+		}
+		key, err := loadPrivateKey(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.Method.Alg() != alg {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE holds a %s key, expected %s", key.Method.Alg(), alg)
+		}
+		if cfg.JWTKeyID != "" {
+			key.ID = cfg.JWTKeyID
+		}
+		ks.add(key)
+		ks.signing = key
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", alg)
+	}
+
+	for _, path := range cfg.JWTVerificationKeyFiles {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := ks.keys[key.ID]; exists {
+			continue
+		}
+		ks.add(key)
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) add(k *Key) {
+	ks.keys[k.ID] = k
+	ks.order = append(ks.order, k)
+}
+
+// Sign signs claims with the current signing key
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks == nil || ks.signing == nil {
+		return "", ErrNotConfigured
+	}
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.signer)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header and
+// rejects tokens whose algorithm does not match that key
+func (ks *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	if ks == nil {
+		return nil, ErrNotConfigured
+	}
+
+	var key *Key
+	if kid, _ := t.Header["kid"].(string); kid != "" {
+		key = ks.keys[kid]
+	} else {
+		key = ks.legacy
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %v", t.Header["kid"])
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// Methods lists the algorithms of all verification keys
+func (ks *KeySet) Methods() []string {
+	if ks == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var methods []string
+	for _, k := range ks.order {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// Sign signs claims with the default key set
+func Sign(claims jwt.Claims) (string, error) {
+	return Default.Sign(claims)
+}
+
+// Keyfunc resolves verification keys from the default key set
+func Keyfunc(t *jwt.Token) (any, error) {
+	return Default.Keyfunc(t)
+}
+
+// Methods lists the algorithms accepted by the default key set
+func Methods() []string {
+	return Default.Methods()
+}
+
+func newHMACKey(secret string) *Key {
+	// The kid must not reveal anything about the secret, so it is derived
+	// from a hash with a fixed label
+	sum := sha256.Sum256([]byte("jwt-kid:" + secret))
+	return &Key{
+		ID:     "hs-" + hex.EncodeToString(sum[:4]),
+		Method: jwt.SigningMethodHS256,
+		signer: []byte(secret),
+		public: []byte(secret),
+	}
+}
+
+// newAsymmetricKey wraps a public key (and optional private key) with its
+// RFC 7638 thumbprint as the kid
+func newAsymmetricKey(pub crypto.PublicKey, priv crypto.PrivateKey) (*Key, error) {
+	k := &Key{public: pub, signer: priv}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+		k.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	jwk, _ := publicJWK(k)
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	k.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+	return k, nil
+}
@@ -2,19 +2,42 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
-	"strconv"
-	"strings"
 
 	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
 	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
-	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ctxKey string
 
-const CtxUserID ctxKey = "userID"
+// CtxClaims holds the *utils.AccessClaims of an authenticated request
+const CtxClaims ctxKey = "claims"
+
+// APIKeyHeaderName carries an API key; it may also be sent as a Bearer token
+const APIKeyHeaderName = "X-API-Key"
+
+// GetClaims returns the access token claims put on the context by
+// AuthMiddleware
+func GetClaims(ctx context.Context) (*utils.AccessClaims, bool) {
+	claims, ok := ctx.Value(CtxClaims).(*utils.AccessClaims)
+	return claims, ok && claims != nil
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// recordRejected writes a presented but refused credential to the audit log
+func recordRejected(r *http.Request, reason string, userID *primitive.ObjectID) {
+	event := utils.NewAuthEvent(r, models.AuthEventTokenRejected, models.AuthOutcomeFailure)
+	event.Reason = reason
+	event.UserID = userID
+	_ = utils.RecordAuthEvent(r.Context(), event)
+}
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	cfg := config.AppConfig
@@ -24,69 +47,116 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		})
 	}
 
-	unauth := func(w http.ResponseWriter) {
-		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenStr, fromCookie := utils.ReadAccessToken(r)
+		if tokenStr == "" {
+			unauthorized(w)
+			return
+		}
 
-	extractToken := func(r *http.Request) string {
-		// 1) Prefer Authorization header: Bearer <token> (case-insensitive)
-		h := strings.TrimSpace(r.Header.Get("Authorization"))
-		if h != "" {
-			parts := strings.Fields(h)
-			if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
-				return parts[1]
-			}
+		// Browsers attach cookies to cross-site requests; Bearer headers
+		// they do not
+		if fromCookie && utils.IsUnsafeMethod(r.Method) && !utils.ValidCSRF(r) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
 		}
-		// 2) Fallback to access_token cookie
-		if c, err := r.Cookie(utils.AccessTokenCookieName); err == nil && c.Value != "" {
-			return c.Value
+
+		claims, err := utils.ParseAccessToken(tokenStr)
+		if err != nil {
+			recordRejected(r, "invalid_token", nil)
+			unauthorized(w)
+			return
 		}
-		return ""
-	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenStr := extractToken(r)
-		if tokenStr == "" {
-			unauth(w)
+		userID, err := claims.UserID()
+		if err != nil {
+			recordRejected(r, "invalid_token", nil)
+			unauthorized(w)
 			return
 		}
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			// Enforce HS* family
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-			}
-			return []byte(cfg.Auth.JWTSecret), nil
-		})
-		if err != nil || !token.Valid {
-			unauth(w)
+		// Logged out, banned or otherwise revoked before expiry
+		revoked, err := utils.IsAccessTokenRevoked(r.Context(), claims.ID)
+		if err != nil {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if revoked {
+			recordRejected(r, "revoked", &userID)
+			unauthorized(w)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			unauth(w)
+		suspended, err := utils.IsUserSuspended(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if suspended {
+			recordRejected(r, "suspended", &userID)
+			http.Error(w, "Account suspended", http.StatusForbidden)
 			return
 		}
 
-		// Extract _id robustly (string or number)
-		var userID string
-		switch v := claims["_id"].(type) {
-		case string:
-			userID = v
-		case float64:
-			userID = strconv.FormatInt(int64(v), 10)
-		default:
-			unauth(w)
+		if claims.Impersonated() {
+			// The admin may have been suspended or demoted since
+			allowed, err := impersonatorAllowed(r, claims)
+			if err != nil {
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if !allowed {
+				recordRejected(r, "impersonator_not_allowed", &userID)
+				unauthorized(w)
+				return
+			}
+			recordImpersonatedRequest(r, claims, userID)
+		}
+
+		ctx := context.WithValue(r.Context(), CtxClaims, claims)
+		ctx = context.WithValue(ctx, CtxPrincipal, &Principal{
+			Type:   PrincipalUser,
+			ID:     claims.Subject,
+			Claims: claims,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AuthenticateMiddleware accepts an API key, in the X-API-Key header or as
+// a Bearer token, as well as everything AuthMiddleware accepts. Handlers
+// behind it read the caller with GetPrincipal.
+func AuthenticateMiddleware(next http.Handler) http.Handler {
+	users := AuthMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeaderName)
+		if key == "" {
+			if token, fromCookie := utils.ReadAccessToken(r); !fromCookie && utils.IsAPIKey(token) {
+				key = token
+			}
+		}
+		if key == "" {
+			users.ServeHTTP(w, r)
 			return
 		}
-		if userID == "" {
-			unauth(w)
+
+		apiKey, err := utils.AuthenticateAPIKey(r.Context(), key, utils.ClientIP(r))
+		if errors.Is(err, utils.ErrInvalidAPIKey) {
+			recordRejected(r, "invalid_api_key", nil)
+			unauthorized(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), CtxUserID, userID)
+		ctx := context.WithValue(r.Context(), CtxPrincipal, &Principal{
+			Type:   PrincipalAPIKey,
+			ID:     apiKey.ID.Hex(),
+			APIKey: apiKey,
+		})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RejectImpersonation blocks sensitive actions, such as changing the
+// password or two-factor settings, for admins impersonating a user. Must
+// run after AuthMiddleware.
+func RejectImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := GetClaims(r.Context()); ok && claims.Impersonated() {
+			utils.ApiError(w, http.StatusForbidden, "Not allowed while impersonating a user")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordImpersonatedRequest logs a request made with an impersonation token
+// and writes it to the audit log
+func recordImpersonatedRequest(r *http.Request, claims *utils.AccessClaims, userID primitive.ObjectID) {
+	log.Printf("[impersonation] admin %s as user %s: %s %s", claims.Act.Subject, claims.Subject, r.Method, r.URL.Path)
+
+	event := utils.NewAuthEvent(r, models.AuthEventImpersonatedRequest, models.AuthOutcomeSuccess)
+	event.UserID = &userID
+	if actorID, err := primitive.ObjectIDFromHex(claims.Act.Subject); err == nil {
+		event.ActorID = &actorID
+	}
+	event.Request = r.Method + " " + r.URL.Path
+	_ = utils.RecordAuthEvent(r.Context(), event)
+}
+
+// impersonatorAllowed reports whether the admin named in the token's act
+// claim may still impersonate users
+func impersonatorAllowed(r *http.Request, claims *utils.AccessClaims) (bool, error) {
+	actorID, err := primitive.ObjectIDFromHex(claims.Act.Subject)
+	if err != nil {
+		return false, nil
+	}
+	return utils.CanImpersonate(r.Context(), actorID)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+)
+
+// Principal types
+const (
+	PrincipalUser   = "user"
+	PrincipalAPIKey = "api_key"
+)
+
+// CtxPrincipal holds the *Principal of an authenticated request
+const CtxPrincipal ctxKey = "principal"
+
+// Principal is whoever authenticated the request: a user with an access
+// token or a partner or job with an API key
+type Principal struct {
+	Type   string
+	ID     string              // user id or API key id
+	Claims *utils.AccessClaims // users only
+	APIKey *models.APIKey      // API keys only
+}
+
+// HasRole reports whether the principal is a user carrying role. API keys
+// have no roles.
+func (p *Principal) HasRole(role string) bool {
+	return p.Claims != nil && p.Claims.HasRole(role)
+}
+
+// HasPermission reports whether a user's roles or grants, or an API key's
+// scopes, include perm
+func (p *Principal) HasPermission(perm string) bool {
+	switch {
+	case p.Claims != nil:
+		return p.Claims.HasPermission(perm)
+	case p.APIKey != nil:
+		return p.APIKey.HasScope(perm)
+	}
+	return false
+}
+
+// HasScope reports whether the access token or API key carries scope
+func (p *Principal) HasScope(scope string) bool {
+	switch {
+	case p.Claims != nil:
+		return p.Claims.HasScope(scope)
+	case p.APIKey != nil:
+		return p.APIKey.HasScope(scope)
+	}
+	return false
+}
+
+// GetPrincipal returns the principal put on the context by AuthMiddleware
+// or AuthenticateMiddleware
+func GetPrincipal(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(CtxPrincipal).(*Principal)
+	return p, ok && p != nil
+}
@@ -14,20 +14,33 @@ type visitor struct {
 	lastSeen time.Time
 }
 
-var (
-	visitors = make(map[string]*visitor)
+// ipRateLimiter keeps one token bucket per client IP
+type ipRateLimiter struct {
+	visitors map[string]*visitor
 	mu       sync.Mutex
-)
+	limit    rate.Limit
+	burst    int
+}
+
+func newIPRateLimiter(limit rate.Limit, burst int) *ipRateLimiter {
+	l := &ipRateLimiter{
+		visitors: make(map[string]*visitor),
+		limit:    limit,
+		burst:    burst,
+	}
+	go l.cleanupVisitors()
+	return l
+}
 
 // getVisitor returns the visitor limiter for an IP, creating one if needed
-func getVisitor(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
+func (l *ipRateLimiter) getVisitor(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	v, exists := visitors[ip]
+	v, exists := l.visitors[ip]
 	if !exists {
-		limiter := rate.NewLimiter(5, 10) // Allows 5 req/sec, with burst up to 10
-		visitors[ip] = &visitor{limiter: limiter, lastSeen: time.Now()}
+		limiter := rate.NewLimiter(l.limit, l.burst)
+		l.visitors[ip] = &visitor{limiter: limiter, lastSeen: time.Now()}
 		return limiter
 	}
 
@@ -36,28 +49,23 @@ func getVisitor(ip string) *rate.Limiter {
 }
 
 // cleanupVisitors removes old visitors every minute
-func cleanupVisitors() {
+func (l *ipRateLimiter) cleanupVisitors() {
 	for {
 		time.Sleep(time.Minute)
-		mu.Lock()
-		for ip, v := range visitors {
+		l.mu.Lock()
+		for ip, v := range l.visitors {
 			if time.Since(v.lastSeen) > 3*time.Minute {
-				delete(visitors, ip)
+				delete(l.visitors, ip)
 			}
 		}
-		mu.Unlock()
+		l.mu.Unlock()
 	}
 }
 
-func init() {
-	go cleanupVisitors()
-}
-
-// RateLimiterMiddleware applies rate limiting per IP
-func RateLimiterMiddleware(next http.Handler) http.Handler {
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-		limiter := getVisitor(ip)
+		limiter := l.getVisitor(ip)
 
 		if !limiter.Allow() {
 			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
@@ -67,3 +75,17 @@ func RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Allows 5 req/sec, with burst up to 10
+var defaultLimiter = newIPRateLimiter(5, 10)
+
+// RateLimiterMiddleware applies rate limiting per IP
+func RateLimiterMiddleware(next http.Handler) http.Handler {
+	return defaultLimiter.middleware(next)
+}
+
+// RateLimit returns a middleware with its own per-IP limit, for endpoints
+// that need a stricter limit than the global one (e.g. sending codes)
+func RateLimit(limit rate.Limit, burst int) func(http.Handler) http.Handler {
+	return newIPRateLimiter(limit, burst).middleware
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+)
+
+// RequireRole rejects callers that are not users carrying one of the given
+// roles. Must run after AuthMiddleware or AuthenticateMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := GetPrincipal(r.Context())
+			if !ok {
+				utils.ApiError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !slices.ContainsFunc(roles, p.HasRole) {
+				utils.ApiError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission rejects callers that lack any of the given permissions;
+// for API keys these are the key's scopes. Must run after AuthMiddleware or
+// AuthenticateMiddleware.
+func RequirePermission(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := GetPrincipal(r.Context())
+			if !ok {
+				utils.ApiError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			for _, perm := range perms {
+				if !p.HasPermission(perm) {
+					utils.ApiError(w, http.StatusForbidden, "Forbidden")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRoleOrScope admits users carrying one of the given roles and API
+// keys granted scope. Must run after AuthenticateMiddleware.
+func RequireRoleOrScope(scope string, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := GetPrincipal(r.Context())
+			if !ok {
+				utils.ApiError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			allowed := slices.ContainsFunc(roles, p.HasRole)
+			if p.Type == PrincipalAPIKey {
+				allowed = p.HasScope(scope)
+			}
+			if !allowed {
+				utils.ApiError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
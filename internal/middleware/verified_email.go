@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RequireVerifiedEmail rejects users whose email address is not verified.
+// Must run after AuthMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetClaims(r.Context())
+		if !ok {
+			utils.ApiError(w, http.StatusUnauthorized, "Missing user id")
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			utils.ApiError(w, http.StatusUnauthorized, "Invalid user id")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+		var user models.User
+		err = users.FindOne(ctx,
+			bson.M{"_id": userID},
+			options.FindOne().SetProjection(bson.M{"emailVerified": 1}),
+		).Decode(&user)
+		if err != nil {
+			utils.ApiError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		if !user.EmailVerified {
+			utils.ApiError(w, http.StatusForbidden, "Email address not verified")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
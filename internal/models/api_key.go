@@ -0,0 +1,31 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey lets a partner or batch job call the API without a user login.
+// Only a hash of the key is stored; Prefix identifies it in lookups and
+// listings.
+type APIKey struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty"        json:"id"`
+	Name       string              `bson:"name"                 json:"name"`
+	Prefix     string              `bson:"prefix"               json:"prefix"`
+	KeyHash    string              `bson:"keyHash"              json:"-"`
+	Scopes     []string            `bson:"scopes"               json:"scopes"`
+	CreatedBy  primitive.ObjectID  `bson:"createdBy"            json:"createdBy"`
+	CreatedAt  time.Time           `bson:"createdAt"            json:"createdAt"`
+	ExpiresAt  *time.Time          `bson:"expiresAt,omitempty"  json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time          `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	LastUsedIP string              `bson:"lastUsedIp,omitempty" json:"lastUsedIp,omitempty"`
+	RevokedAt  *time.Time          `bson:"revokedAt,omitempty"  json:"revokedAt,omitempty"`
+	RevokedBy  *primitive.ObjectID `bson:"revokedBy,omitempty"  json:"revokedBy,omitempty"`
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Auth event types
+const (
+	AuthEventSignup        = "signup"
+	AuthEventLogin         = "login"
+	AuthEventRefresh       = "refresh"
+	AuthEventLogout        = "logout"
+	AuthEventTokenRejected = "token_rejected"
+
+	AuthEventImpersonationStart  = "impersonation_start"
+	AuthEventImpersonatedRequest = "impersonated_request"
+
+	AuthEventDeletionRequested = "account_deletion_requested"
+	AuthEventDeletionCancelled = "account_deletion_cancelled"
+	AuthEventAccountDeleted    = "account_deleted"
+
+	AuthEventAdminSuspend   = "admin_suspend"
+	AuthEventAdminUnsuspend = "admin_unsuspend"
+	AuthEventAdminLogout    = "admin_logout"
+	AuthEventAdminResetMFA  = "admin_reset_mfa"
+)
+
+// Auth event outcomes
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+)
+
+// Login methods
+const (
+	AuthMethodPassword     = "password"
+	AuthMethodPasswordless = "passwordless"
+	AuthMethodPasskey      = "passkey"
+	AuthMethodMFA          = "mfa"
+	AuthMethodOAuth        = "oauth" // followed by ":<provider>"
+)
+
+// AuthEvent is an entry in the append-only authentication audit log
+type AuthEvent struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty"        json:"id"`
+	Type       string              `bson:"type"                 json:"type"`
+	Outcome    string              `bson:"outcome"              json:"outcome"`
+	Reason     string              `bson:"reason,omitempty"     json:"reason,omitempty"`
+	Method     string              `bson:"method,omitempty"     json:"method,omitempty"`
+	UserID     *primitive.ObjectID `bson:"userId,omitempty"     json:"userId,omitempty"`
+	SessionID  *primitive.ObjectID `bson:"sessionId,omitempty"  json:"sessionId,omitempty"`
+	ActorID    *primitive.ObjectID `bson:"actorId,omitempty"    json:"actorId,omitempty"`    // admin impersonating the user
+	Identifier string              `bson:"identifier,omitempty" json:"identifier,omitempty"` // as typed, for failed logins
+	Request    string              `bson:"request,omitempty"    json:"request,omitempty"`    // method and path, for impersonated requests
+	IP         string              `bson:"ip"                   json:"ip"`
+	UserAgent  string              `bson:"userAgent"            json:"userAgent"`
+	CreatedAt  time.Time           `bson:"createdAt"            json:"createdAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImpersonationToken records an access token an admin was given to act as
+// a user, so it can be revoked when either account is signed out. Entries
+// are removed by a TTL index once the token has expired.
+type ImpersonationToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	JTI       string             `bson:"jti"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	ActorID   primitive.ObjectID `bson:"actorId"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts recent failed password logins for one identifier
+// (email or phone as typed, hashed so unknown identifiers are not stored).
+// The document expires once the failure window passes without failures.
+type LoginAttempt struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	IdentifierHash string             `bson:"identifierHash"`
+	Failures       int                `bson:"failures"`
+	LockedUntil    *time.Time         `bson:"lockedUntil,omitempty"`
+	LastIP         string             `bson:"lastIp"`
+	LastFailureAt  time.Time          `bson:"lastFailureAt"`
+	ExpiresAt      time.Time          `bson:"expiresAt"`
+}
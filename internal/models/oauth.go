@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity links a user to an account at an OpenID Connect provider
+type Identity struct {
+	Provider string    `bson:"provider"        json:"provider"`
+	Subject  string    `bson:"subject"         json:"-"`
+	Email    string    `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt time.Time `bson:"linkedAt"        json:"linkedAt"`
+}
+
+// OAuthState is an authorization request in flight. It binds the provider's
+// callback to the browser that started the login and keeps the PKCE
+// verifier and nonce server side.
+type OAuthState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StateHash    string             `bson:"stateHash"     json:"-"`
+	Provider     string             `bson:"provider"      json:"provider"`
+	Nonce        string             `bson:"nonce"         json:"-"`
+	CodeVerifier string             `bson:"codeVerifier"  json:"-"`
+	ExpiresAt    time.Time          `bson:"expiresAt"     json:"expiresAt"`
+	CreatedAt    time.Time          `bson:"createdAt"     json:"createdAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// One-time code purposes
+const (
+	OTPPurposePhoneVerification = "phone_verification"
+	OTPPurposePasswordlessLogin = "passwordless_login"
+)
+
+// OTPCode is a short numeric one-time code sent to a phone number or email
+// address. Only a keyed hash of the code is stored.
+type OTPCode struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty"    json:"id"`
+	Purpose     string              `bson:"purpose"          json:"purpose"`
+	Target      string              `bson:"target"           json:"target"`
+	UserID      *primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	CodeHash    string              `bson:"codeHash"         json:"-"`
+	Attempts    int                 `bson:"attempts"         json:"attempts"`
+	MaxAttempts int                 `bson:"maxAttempts"      json:"maxAttempts"`
+	ExpiresAt   time.Time           `bson:"expiresAt"        json:"expiresAt"`
+	CreatedAt   time.Time           `bson:"createdAt"        json:"createdAt"`
+}
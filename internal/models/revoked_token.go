@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons an access token was revoked
+const (
+	RevocationReasonLogout         = "logout"
+	RevocationReasonSessionRevoked = "session_revoked"
+	RevocationReasonPasswordChange = "password_change"
+	RevocationReasonPasswordReset  = "password_reset"
+	RevocationReasonAccountLinked  = "account_linked"
+	RevocationReasonTokenReuse     = "refresh_token_reuse"
+	RevocationReasonAdmin          = "admin"
+	RevocationReasonAccountDeleted = "account_deleted"
+)
+
+// RevokedToken denies an access token before it expires. Entries are
+// removed by a TTL index once the token would have expired anyway.
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	JTI       string             `bson:"jti"`
+	UserID    primitive.ObjectID `bson:"userId,omitempty"`
+	Reason    string             `bson:"reason"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
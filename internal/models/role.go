@@ -0,0 +1,76 @@
+package models
+
+import "slices"
+
+// Roles
+const (
+	RoleAdmin     = "admin"
+	RoleCounselor = "counselor"
+	RoleStudent   = "student"
+)
+
+// Permissions checked by staff endpoints
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermUsersRoles     = "users:roles"
+	PermSessionsRevoke = "sessions:revoke"
+	PermProfilesRead   = "profiles:read"
+	PermAuditRead      = "audit:read"
+)
+
+// RolePermissions lists what each role may do. Self-service endpoints
+// (own profile, own sessions) need no permission.
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUsersRead,
+		PermUsersWrite,
+		PermUsersRoles,
+		PermSessionsRevoke,
+		PermProfilesRead,
+		PermAuditRead,
+	},
+	RoleCounselor: {
+		PermUsersRead,
+		PermProfilesRead,
+	},
+	RoleStudent: {},
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// IsValidPermission reports whether perm is granted by any role
+func IsValidPermission(perm string) bool {
+	for _, perms := range RolePermissions {
+		if slices.Contains(perms, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the roles, or the extra grants, include perm
+func HasPermission(roles, extra []string, perm string) bool {
+	if slices.Contains(extra, perm) {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(RolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveRoles returns the user's roles. Accounts created before roles
+// existed have none stored and are students.
+func (u *User) EffectiveRoles() []string {
+	if len(u.Roles) == 0 {
+		return []string{RoleStudent}
+	}
+	return u.Roles
+}
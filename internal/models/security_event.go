@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Security event types
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventPasskeyCloned     = "passkey_sign_count_regression"
+	SecurityEventAccountLocked     = "account_locked"
+)
+
+// SecurityEvent records something suspicious that happened to an account
+type SecurityEvent struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"       json:"id"`
+	Type      string              `bson:"type"                json:"type"`
+	UserID    *primitive.ObjectID `bson:"userId,omitempty"    json:"userId,omitempty"`
+	SessionID *primitive.ObjectID `bson:"sessionId,omitempty" json:"sessionId,omitempty"`
+	IP        string              `bson:"ip"                  json:"ip"`
+	UserAgent string              `bson:"userAgent"           json:"userAgent"`
+	Details   string              `bson:"details,omitempty"   json:"details,omitempty"`
+	CreatedAt time.Time           `bson:"createdAt"           json:"createdAt"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a single signed-in device. Each session owns one refresh token
+// family: rotating the refresh token keeps the session and remembers the old
+// token as spent, so a replayed token can be traced back to its family.
+// The ids of access tokens issued for the session are kept until they
+// expire so they can be revoked together with it.
+type Session struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty"    json:"id"`
+	UserID           primitive.ObjectID `bson:"userId"           json:"-"`
+	RefreshTokenHash string             `bson:"refreshTokenHash" json:"-"`
+	SpentTokenHashes []string           `bson:"spentTokenHashes,omitempty" json:"-"`
+	AccessTokens     []IssuedToken      `bson:"accessTokens,omitempty" json:"-"`
+	UserAgent        string             `bson:"userAgent"        json:"userAgent"`
+	IP               string             `bson:"ip"               json:"ip"`
+	CreatedAt        time.Time          `bson:"createdAt"        json:"createdAt"`
+	LastUsedAt       time.Time          `bson:"lastUsedAt"       json:"lastUsedAt"`
+	ExpiresAt        time.Time          `bson:"expiresAt"        json:"expiresAt"`
+}
+
+// IssuedToken is an access token handed out for a session
+type IssuedToken struct {
+	JTI       string    `bson:"jti"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
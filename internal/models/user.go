@@ -9,10 +9,34 @@ import (
 type User struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty"           json:"id"`
 	Email               string             `bson:"email"                   json:"email"`
-	Phone               string             `bson:"phone"                   json:"phone"`
+	EmailVerified       bool               `bson:"emailVerified"           json:"emailVerified"`
+	EmailVerifiedAt     *time.Time         `bson:"emailVerifiedAt,omitempty" json:"emailVerifiedAt,omitempty"`
+	Phone               string             `bson:"phone,omitempty"         json:"phone"`
+	PhoneVerified       bool               `bson:"phoneVerified"           json:"phoneVerified"`
+	PhoneVerifiedAt     *time.Time         `bson:"phoneVerifiedAt,omitempty" json:"phoneVerifiedAt,omitempty"`
 	Password            string             `bson:"password"                json:"-"`
-	RefreshTokenHash    string             `bson:"refreshTokenHash,omitempty" json:"-"`
-	RefreshTokenExpires *time.Time         `bson:"refreshTokenExpires,omitempty" json:"-"`
+
+	// Access control
+	Roles               []string           `bson:"roles,omitempty"         json:"roles"`
+	Permissions         []string           `bson:"permissions,omitempty"   json:"permissions,omitempty"` // granted on top of the roles
+	Suspended           bool               `bson:"suspended"               json:"suspended"`
+	SuspendedAt         *time.Time         `bson:"suspendedAt,omitempty"   json:"suspendedAt,omitempty"`
+	SuspensionReason    string             `bson:"suspensionReason,omitempty" json:"suspensionReason,omitempty"`
+
+	// Account deletion, requested by the user and purged once scheduled
+	DeletionRequestedAt *time.Time         `bson:"deletionRequestedAt,omitempty" json:"deletionRequestedAt,omitempty"`
+	DeletionScheduledAt *time.Time         `bson:"deletionScheduledAt,omitempty" json:"deletionScheduledAt,omitempty"`
+	PurgingAt           *time.Time         `bson:"purgingAt,omitempty"     json:"-"` // set once the purge has started
+
+	// Two-factor authentication (TOTP)
+	MFAEnabled          bool               `bson:"mfaEnabled"              json:"mfaEnabled"`
+	TOTPSecret          string             `bson:"totpSecret,omitempty"    json:"-"`
+	TOTPPendingSecret   string             `bson:"totpPendingSecret,omitempty" json:"-"`
+	TOTPLastUsedStep    int64              `bson:"totpLastUsedStep,omitempty" json:"-"`
+	RecoveryCodeHashes  []string           `bson:"recoveryCodeHashes,omitempty" json:"-"`
+
+	// Linked social login accounts
+	Identities          []Identity         `bson:"identities,omitempty"   json:"identities,omitempty"`
 	
 	// Profile completion fields
 	ProfileCompletion   bool               `bson:"profileCompletion"      json:"profileCompletion"`
@@ -50,7 +74,8 @@ type HigherEducation struct {
 }
 
 type LanguageTest struct {
-	TestType  string `bson:"testType"  json:"testType"`  // IELTS, TOEFL, etc.
-	Score     string `bson:"score"     json:"score"`
-	TestYear  string `bson:"testYear"  json:"testYear"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TestType string             `bson:"testType"      json:"testType"` // IELTS, TOEFL, etc.
+	Score    string             `bson:"score"         json:"score"`
+	TestYear string             `bson:"testYear"      json:"testYear"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User token purposes
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMagicLink         = "magic_link"
+	TokenPurposeMFAChallenge      = "mfa_challenge"
+)
+
+// UserToken is a single-use token sent to a user out of band (e.g. by email).
+// Only the SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId"        json:"userId"`
+	Purpose   string             `bson:"purpose"       json:"purpose"`
+	TokenHash string             `bson:"tokenHash"     json:"-"`
+	Attempts  int                `bson:"attempts"      json:"attempts"`
+	ExpiresAt time.Time          `bson:"expiresAt"     json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"     json:"createdAt"`
+}
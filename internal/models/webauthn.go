@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebAuthn ceremonies
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey registered to a user
+type WebAuthnCredential struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"        json:"id"`
+	UserID       primitive.ObjectID `bson:"userId"               json:"-"`
+	CredentialID string             `bson:"credentialId"         json:"credentialId"` // base64url
+	PublicKey    []byte             `bson:"publicKey"            json:"-"`            // COSE_Key
+	SignCount    uint32             `bson:"signCount"            json:"-"`
+	AAGUID       []byte             `bson:"aaguid,omitempty"     json:"-"`
+	Transports   []string           `bson:"transports,omitempty" json:"transports,omitempty"`
+	Name         string             `bson:"name"                 json:"name"`
+	CreatedAt    time.Time          `bson:"createdAt"            json:"createdAt"`
+	LastUsedAt   *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnChallenge is an outstanding registration or login ceremony.
+// Challenges are single use and expire with the ceremony timeout.
+type WebAuthnChallenge struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"    json:"id"`
+	Ceremony  string              `bson:"ceremony"         json:"ceremony"`
+	Challenge string              `bson:"challenge"        json:"-"` // base64url
+	UserID    *primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	ExpiresAt time.Time           `bson:"expiresAt"        json:"expiresAt"`
+	CreatedAt time.Time           `bson:"createdAt"        json:"createdAt"`
+}
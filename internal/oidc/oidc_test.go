@@ -0,0 +1,501 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "client-123"
+	testNonce    = "nonce-abc"
+	testCode     = "code-xyz"
+	testVerifier = "verifier-xyz"
+)
+
+// testIssuer is a stand-in OpenID provider serving discovery, JWKS and the
+// token endpoint
+type testIssuer struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	issuer  string // overrides the issuer in the discovery document
+	broken  bool   // discovery and JWKS return 500
+	idToken string // returned by the token endpoint
+
+	discoveryHits atomic.Int32
+	jwksHits      atomic.Int32
+	tokenForm     atomic.Value
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	ti := &testIssuer{keys: map[string]crypto.Signer{}}
+	ti.addRSAKey(t, "rsa-1")
+	ti.addECKey(t, "ec-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		ti.discoveryHits.Add(1)
+		ti.mu.Lock()
+		issuer, broken := ti.issuer, ti.broken
+		ti.mu.Unlock()
+		if broken {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if issuer == "" {
+			issuer = ti.srv.URL
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": ti.srv.URL + "/authorize",
+			"token_endpoint":         ti.srv.URL + "/token",
+			"jwks_uri":               ti.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		ti.jwksHits.Add(1)
+		ti.mu.Lock()
+		defer ti.mu.Unlock()
+		if ti.broken {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		keys := []jwk{}
+		for kid, k := range ti.keys {
+			keys = append(keys, publicJWK(kid, k.Public()))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ti.tokenForm.Store(r.PostForm)
+		if r.PostForm.Get("code") != testCode || r.PostForm.Get("code_verifier") != testVerifier {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error":             "invalid_grant",
+				"error_description": "bad code",
+			})
+			return
+		}
+		ti.mu.Lock()
+		idToken := ti.idToken
+		ti.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"access_token": "at", "id_token": idToken})
+	})
+
+	ti.srv = httptest.NewServer(mux)
+	t.Cleanup(ti.srv.Close)
+	return ti
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (ti *testIssuer) addRSAKey(t *testing.T, kid string) {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti.mu.Lock()
+	ti.keys[kid] = k
+	ti.mu.Unlock()
+}
+
+func (ti *testIssuer) addECKey(t *testing.T, kid string) {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti.mu.Lock()
+	ti.keys[kid] = k
+	ti.mu.Unlock()
+}
+
+func publicJWK(kid string, pub crypto.PublicKey) jwk {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: kid, Use: "sig", N: enc(pub.N.Bytes()), E: enc(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return jwk{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256", X: enc(x), Y: enc(y)}
+	}
+	panic("unsupported key type")
+}
+
+// claims returns ID token claims that the provider under test accepts
+func (ti *testIssuer) claims() *Claims {
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ti.srv.URL,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:         testNonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Test User",
+	}
+}
+
+// sign signs claims with the key kid, using the method that matches its type
+func (ti *testIssuer) sign(t *testing.T, kid string, claims *Claims) string {
+	t.Helper()
+	ti.mu.Lock()
+	key := ti.keys[kid]
+	ti.mu.Unlock()
+
+	var method jwt.SigningMethod = jwt.SigningMethodRS256
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func (ti *testIssuer) provider(secret string) *Provider {
+	return NewProvider(config.OIDCProviderConfig{
+		Name:         "test",
+		Issuer:       ti.srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: secret,
+		RedirectURL:  "https://app.example.com/callback",
+		Scopes:       []string{"openid", "email"},
+	}, ti.srv.Client())
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ti := newTestIssuer(t)
+	unpublished, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		kid     string
+		nonce   string
+		mutate  func(c *Claims)
+		raw     func(t *testing.T, c *Claims) string
+		wantErr bool
+	}{
+		{name: "valid RS256", kid: "rsa-1"},
+		{name: "valid ES256", kid: "ec-1"},
+		{
+			name: "multiple audiences with our azp",
+			kid:  "rsa-1",
+			mutate: func(c *Claims) {
+				c.Audience = jwt.ClaimStrings{testClientID, "other"}
+				c.AuthorizedParty = testClientID
+			},
+		},
+		{
+			name:    "wrong issuer",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.Issuer = "https://evil.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.Audience = jwt.ClaimStrings{"someone-else"} },
+			wantErr: true,
+		},
+		{
+			name:    "multiple audiences with another azp",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.Audience = jwt.ClaimStrings{testClientID, "other"}; c.AuthorizedParty = "other" },
+			wantErr: true,
+		},
+		{
+			name:    "multiple audiences without azp",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.Audience = jwt.ClaimStrings{testClientID, "other"} },
+			wantErr: true,
+		},
+		{name: "nonce mismatch", kid: "rsa-1", nonce: "another-nonce", wantErr: true},
+		{
+			name:    "expired",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-5 * time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name:    "no expiry",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.ExpiresAt = nil },
+			wantErr: true,
+		},
+		{
+			name:    "issued in the future",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) },
+			wantErr: true,
+		},
+		{
+			name:    "missing subject",
+			kid:     "rsa-1",
+			mutate:  func(c *Claims) { c.Subject = "" },
+			wantErr: true,
+		},
+		{
+			name: "signed with a key the provider does not publish",
+			raw: func(t *testing.T, c *Claims) string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+				tok.Header["kid"] = "rsa-1"
+				raw, err := tok.SignedString(unpublished)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 with the client id as secret",
+			raw: func(t *testing.T, c *Claims) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(testClientID))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+	}
+
+	p := ti.provider("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ti.claims()
+			if tt.mutate != nil {
+				tt.mutate(c)
+			}
+			var raw string
+			if tt.raw != nil {
+				raw = tt.raw(t, c)
+			} else {
+				raw = ti.sign(t, tt.kid, c)
+			}
+			nonce := testNonce
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			got, err := p.VerifyIDToken(context.Background(), raw, nonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("err = %v, want %v", err, ErrInvalidIDToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if got.Subject != "user-1" || got.Email != "user@example.com" || !got.EmailVerified {
+				t.Fatalf("unexpected claims %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := ti.provider("")
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, ti.sign(t, "rsa-1", ti.claims()), testNonce); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if got := ti.jwksHits.Load(); got != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", got)
+	}
+
+	// The provider starts signing with a new key
+	ti.addRSAKey(t, "rsa-2")
+	rotated := ti.sign(t, "rsa-2", ti.claims())
+
+	// Within the refresh interval an unknown kid does not refetch
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidIDToken)
+	}
+	if got := ti.jwksHits.Load(); got != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", got)
+	}
+
+	p.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	p.mu.Unlock()
+
+	if _, err := p.VerifyIDToken(ctx, rotated, testNonce); err != nil {
+		t.Fatalf("VerifyIDToken after rotation: %v", err)
+	}
+	if got := ti.jwksHits.Load(); got != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", got)
+	}
+
+	// Tokens signed with the old key still verify from the new set
+	if _, err := p.VerifyIDToken(ctx, ti.sign(t, "rsa-1", ti.claims()), testNonce); err != nil {
+		t.Fatalf("VerifyIDToken with the old key: %v", err)
+	}
+	if got := ti.jwksHits.Load(); got != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", got)
+	}
+}
+
+func TestVerifyIDTokenSharesKeyFetches(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := ti.provider("")
+	raw := ti.sign(t, "ec-1", ti.claims())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.VerifyIDToken(context.Background(), raw, testNonce)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("VerifyIDToken: %v", err)
+		}
+	}
+	if got := ti.jwksHits.Load(); got != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", got)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := ti.provider("")
+	ctx := context.Background()
+
+	u, err := p.AuthCodeURL(ctx, "state-1", testNonce, "challenge-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(u, ti.srv.URL+"/authorize?") {
+		t.Fatalf("AuthCodeURL = %q", u)
+	}
+	for _, want := range []string{"client_id=" + testClientID, "state=state-1", "nonce=" + testNonce, "code_challenge=challenge-1", "code_challenge_method=S256"} {
+		if !strings.Contains(u, want) {
+			t.Fatalf("AuthCodeURL %q is missing %q", u, want)
+		}
+	}
+
+	if _, err := p.AuthCodeURL(ctx, "state-2", testNonce, "challenge-2"); err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if got := ti.discoveryHits.Load(); got != 1 {
+		t.Fatalf("discovery fetched %d times, want 1", got)
+	}
+
+	// A stale document is refetched, and kept if the provider is down
+	ti.mu.Lock()
+	ti.broken = true
+	ti.mu.Unlock()
+	p.mu.Lock()
+	p.metaFetchedAt = time.Now().Add(-2 * metadataRefreshInterval)
+	p.mu.Unlock()
+
+	if _, err := p.AuthCodeURL(ctx, "state-3", testNonce, "challenge-3"); err != nil {
+		t.Fatalf("AuthCodeURL with the provider down: %v", err)
+	}
+	if got := ti.discoveryHits.Load(); got != 2 {
+		t.Fatalf("discovery fetched %d times, want 2", got)
+	}
+	if _, err := p.AuthCodeURL(ctx, "state-4", testNonce, "challenge-4"); err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if got := ti.discoveryHits.Load(); got != 2 {
+		t.Fatalf("discovery retried %d times within the retry interval", got-1)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	ti := newTestIssuer(t)
+	ti.issuer = "https://evil.example.com"
+
+	if _, err := ti.provider("").AuthCodeURL(context.Background(), "s", "n", "c"); err == nil {
+		t.Fatal("AuthCodeURL accepted a discovery document for another issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	ti := newTestIssuer(t)
+	want := ti.sign(t, "rsa-1", ti.claims())
+	ti.idToken = want
+	p := ti.provider("shh")
+	ctx := context.Background()
+
+	got, err := p.Exchange(ctx, testCode, testVerifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Exchange returned %q, want the provider's id token", got)
+	}
+
+	form := ti.tokenForm.Load().(url.Values)
+	for key, want := range map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     testClientID,
+		"client_secret": "shh",
+		"redirect_uri":  "https://app.example.com/callback",
+	} {
+		if v := form[key]; len(v) != 1 || v[0] != want {
+			t.Fatalf("token request %s = %v, want %q", key, v, want)
+		}
+	}
+
+	if _, err := p.Exchange(ctx, "wrong-code", testVerifier); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange with a bad code: err = %v", err)
+	}
+	if _, err := p.Exchange(ctx, testCode, "wrong-verifier"); err == nil {
+		t.Fatal("Exchange accepted a wrong code verifier")
+	}
+}
@@ -0,0 +1,70 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// prefixLength is the number of hex characters used to bucket hashes, as in
+// the k-anonymity range model of the "Have I Been Pwned" API
+const prefixLength = 5
+
+// BreachedList is an in-memory set of breached password SHA-1 hashes,
+// bucketed by hash prefix. A lookup only ever compares the password's hash
+// against the suffixes in its own bucket.
+type BreachedList struct {
+	buckets map[string][]string
+}
+
+// LoadBreachedList reads a file of uppercase or lowercase SHA-1 hex hashes, one
+// per line. Lines may carry a ":count" suffix as in the HIBP downloads.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	list := &BreachedList{buckets: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" || strings.HasPrefix(hash, "#") {
+			continue
+		}
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list line %d: not a SHA-1 hash", line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached password list line %d: not a SHA-1 hash", line)
+		}
+		prefix := hash[:prefixLength]
+		list.buckets[prefix] = append(list.buckets[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	for prefix := range list.buckets {
+		slices.Sort(list.buckets[prefix])
+	}
+	return list, nil
+}
+
+// Contains reports whether pw is in the breached list
+func (l *BreachedList) Contains(pw string) bool {
+	sum := sha1.Sum([]byte(pw))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := l.buckets[hash[:prefixLength]]
+	_, found := slices.BinarySearch(suffixes, hash[prefixLength:])
+	return found
+}
@@ -0,0 +1,78 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedList(t *testing.T) {
+	path := writeBreachedList(t,
+		"# comment",
+		"",
+		strings.ToUpper(sha1Hex("password123"))+":24523",
+		sha1Hex("letmein2024"),
+		"  "+strings.ToUpper(sha1Hex("qwertyuiop"))+"  ",
+	)
+	list, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedList: %v", err)
+	}
+
+	for pw, want := range map[string]bool{
+		"password123":    true,
+		"letmein2024":    true,
+		"qwertyuiop":     true,
+		"Password123":    false,
+		"lantern meadow": false,
+		"":               false,
+	} {
+		if got := list.Contains(pw); got != want {
+			t.Errorf("Contains(%q) = %t, want %t", pw, got, want)
+		}
+	}
+
+	policy := Policy{MinLength: 8, MaxBytes: BcryptMaxBytes, Breached: list}
+	if err := policy.Validate("password123"); err == nil || !strings.Contains(err.Error(), "data breach") {
+		t.Fatalf("breached password: err = %v", err)
+	}
+	if err := policy.Validate("lantern meadow"); err != nil {
+		t.Fatalf("password not in the list: %v", err)
+	}
+}
+
+func TestLoadBreachedListRejectsMalformedFiles(t *testing.T) {
+	tests := map[string]string{
+		"too short": "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD",
+		"not hex":   "ZBAA61E4C9B93F3F0682250B6CF8331B7EE68FD8",
+		"sha-256":   "5E884898DA28047151D0E56F8DC6292773603D0D6AABBDD62A11EF721D1542D8",
+	}
+	for name, line := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadBreachedList(writeBreachedList(t, sha1Hex("ok"), line)); err == nil {
+				t.Fatal("LoadBreachedList accepted a malformed line")
+			}
+		})
+	}
+
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("LoadBreachedList accepted a missing file")
+	}
+}
@@ -0,0 +1,130 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+)
+
+// BcryptMaxBytes is the longest input bcrypt accepts
+const BcryptMaxBytes = 72
+
+// Policy describes what an acceptable password looks like
+type Policy struct {
+	MinLength     int
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Breached      *BreachedList
+}
+
+// Default is the policy used by Validate. It is replaced by Init on startup.
+var Default = Policy{MinLength: 8, MaxBytes: BcryptMaxBytes}
+
+// Init builds the policy from the auth configuration and loads the breached
+// password list when one is configured
+func Init(cfg config.AuthConfig) error {
+	p := Policy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxBytes:      cfg.PasswordMaxBytes,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+	}
+	if p.MaxBytes <= 0 || p.MaxBytes > BcryptMaxBytes {
+		p.MaxBytes = BcryptMaxBytes
+	}
+
+	if cfg.BreachedPasswordsFile != "" {
+		list, err := LoadBreachedList(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return err
+		}
+		p.Breached = list
+	}
+
+	Default = p
+	return nil
+}
+
+// Validate checks pw against the default policy
+func Validate(pw string, personal ...string) error {
+	return Default.Validate(pw, personal...)
+}
+
+// Validate checks pw against the policy. personal holds values the password
+// must not contain, such as the user's email and phone number. The returned
+// error message is safe to show to the user.
+func (p Policy) Validate(pw string, personal ...string) error {
+	if len(pw) == 0 {
+		return errors.New("Missing password")
+	}
+	if len([]rune(pw)) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters", p.MinLength)
+	}
+	if len(pw) > p.MaxBytes {
+		return fmt.Errorf("Password must be at most %d bytes", p.MaxBytes)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		return errors.New("Password must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		return errors.New("Password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		return errors.New("Password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return errors.New("Password must contain a symbol")
+	}
+
+	lower := strings.ToLower(pw)
+	for _, value := range personalFragments(personal) {
+		if strings.Contains(lower, value) {
+			return errors.New("Password must not contain your email or phone number")
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(pw) {
+		return errors.New("This password has appeared in a data breach, please choose another one")
+	}
+
+	return nil
+}
+
+// personalFragments expands personal values into the lowercase fragments a
+// password may not contain: the full value and, for emails, the local part
+func personalFragments(personal []string) []string {
+	var out []string
+	for _, v := range personal {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if len(v) < 4 {
+			continue
+		}
+		out = append(out, v)
+		if local, _, ok := strings.Cut(v, "@"); ok && len(local) >= 4 {
+			out = append(out, local)
+		}
+	}
+	return out
+}
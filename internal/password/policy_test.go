@@ -0,0 +1,108 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	base := Policy{MinLength: 8, MaxBytes: BcryptMaxBytes}
+	strict := Policy{MinLength: 8, MaxBytes: BcryptMaxBytes, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		personal []string
+		wantErr  string
+	}{
+		{name: "acceptable", policy: base, password: "lantern meadow"},
+		{name: "empty", policy: base, password: "", wantErr: "Missing password"},
+		{name: "one short", policy: base, password: "abcdefg", wantErr: "at least 8 characters"},
+		{name: "exactly the minimum", policy: base, password: "abcdefgh"},
+		{name: "length counts characters, not bytes", policy: base, password: "ääääääää"},
+		{name: "multibyte one short", policy: base, password: "äääääää", wantErr: "at least 8 characters"},
+		{name: "at the bcrypt limit", policy: base, password: strings.Repeat("a", BcryptMaxBytes)},
+		{name: "over the bcrypt limit", policy: base, password: strings.Repeat("a", BcryptMaxBytes+1), wantErr: "at most 72 bytes"},
+		{name: "limit counts bytes", policy: base, password: strings.Repeat("ä", 37), wantErr: "at most 72 bytes"},
+		{name: "lower max bytes", policy: Policy{MinLength: 8, MaxBytes: 10}, password: "abcdefghijk", wantErr: "at most 10 bytes"},
+
+		{name: "all classes", policy: strict, password: "Lantern-42"},
+		{name: "no uppercase", policy: strict, password: "lantern-42", wantErr: "uppercase"},
+		{name: "no lowercase", policy: strict, password: "LANTERN-42", wantErr: "lowercase"},
+		{name: "no digit", policy: strict, password: "Lantern-xy", wantErr: "digit"},
+		{name: "no symbol", policy: strict, password: "Lantern420", wantErr: "symbol"},
+		{name: "space counts as symbol", policy: strict, password: "Lantern 42"},
+
+		{
+			name:     "contains the email",
+			policy:   base,
+			password: "my jane.doe@example.com pw",
+			personal: []string{"jane.doe@example.com"},
+			wantErr:  "email or phone",
+		},
+		{
+			name:     "contains the email local part, any case",
+			policy:   base,
+			password: "xxJANE.DOExx",
+			personal: []string{"Jane.Doe@Example.com"},
+			wantErr:  "email or phone",
+		},
+		{
+			name:     "contains the phone",
+			policy:   base,
+			password: "call 01712345678",
+			personal: []string{"jane@example.com", "01712345678"},
+			wantErr:  "email or phone",
+		},
+		{
+			name:     "short local parts are ignored",
+			policy:   base,
+			password: "joe-and-friends",
+			personal: []string{"joe@example.com"},
+		},
+		{
+			name:     "blank personal values are ignored",
+			policy:   base,
+			password: "lantern meadow",
+			personal: []string{"", "   "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.personal...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	previous := Default
+	t.Cleanup(func() { Default = previous })
+
+	err := Init(config.AuthConfig{PasswordMinLength: 12, PasswordMaxBytes: 200, PasswordRequireDigit: true})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if Default.MinLength != 12 || Default.MaxBytes != BcryptMaxBytes || !Default.RequireDigit || Default.Breached != nil {
+		t.Fatalf("Default = %+v", Default)
+	}
+	if err := Validate("lantern meadow"); err == nil || !strings.Contains(err.Error(), "digit") {
+		t.Fatalf("Validate used another policy: err = %v", err)
+	}
+
+	if err := Init(config.AuthConfig{BreachedPasswordsFile: t.TempDir() + "/missing.txt"}); err == nil {
+		t.Fatal("Init accepted a missing breached password list")
+	}
+}
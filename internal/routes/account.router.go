@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+)
+
+func RegisterAccountRoutes(mux *http.ServeMux) {
+	// Protected: the account owner only, never an impersonating admin
+	mux.Handle("GET /api/v1/account/export",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ExportAccount),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/account",
+		middleware.Chain(
+			http.HandlerFunc(handlers.DeleteAccount),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/account/deletion/cancel",
+		middleware.Chain(
+			http.HandlerFunc(handlers.CancelAccountDeletion),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+)
+
+func RegisterAdminRoutes(mux *http.ServeMux) {
+	// Protected: admins only; read endpoints also take API keys with the
+	// matching scope
+	mux.Handle("GET /api/v1/admin/users",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminListUsers),
+			middleware.AuthenticateMiddleware,
+			middleware.RequireRoleOrScope(models.PermUsersRead, models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("GET /api/v1/admin/users/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminGetUser),
+			middleware.AuthenticateMiddleware,
+			middleware.RequireRoleOrScope(models.PermUsersRead, models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/users/{id}/suspend",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminSuspendUser),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/users/{id}/unsuspend",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminUnsuspendUser),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/users/{id}/logout",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminLogoutUser),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/users/{id}/mfa/reset",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminResetUserMFA),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/users/{id}/impersonate",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminImpersonateUser),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("GET /api/v1/admin/auth-events",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminListAuthEvents),
+			middleware.AuthenticateMiddleware,
+			middleware.RequireRoleOrScope(models.PermAuditRead, models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/api-keys",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminCreateAPIKey),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("GET /api/v1/admin/api-keys",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminListAPIKeys),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/admin/api-keys/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.AdminRevokeAPIKey),
+			middleware.AuthMiddleware,
+			middleware.RequireRole(models.RoleAdmin),
+		),
+	)
+}
@@ -2,9 +2,11 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
 	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"golang.org/x/time/rate"
 )
 
 func RegisterAuthRoutes(mux *http.ServeMux) {
@@ -14,6 +16,31 @@ func RegisterAuthRoutes(mux *http.ServeMux) {
 	mux.Handle("POST /api/v1/auth/login",  middleware.Chain(http.HandlerFunc(handlers.Login)))
 	mux.Handle("POST /api/v1/auth/logout", middleware.Chain(http.HandlerFunc(handlers.Logout)))
 	mux.Handle("POST /api/v1/auth/refresh", middleware.Chain(http.HandlerFunc(handlers.Refresh)))
+	mux.Handle("POST /api/v1/auth/verify-email", middleware.Chain(http.HandlerFunc(handlers.VerifyEmail)))
+	mux.Handle("POST /api/v1/auth/password/forgot", middleware.Chain(http.HandlerFunc(handlers.ForgotPassword)))
+	mux.Handle("POST /api/v1/auth/password/reset", middleware.Chain(http.HandlerFunc(handlers.ResetPassword)))
+
+	// Passwordless login: stricter per-IP limit on top of the global one
+	mux.Handle("POST /api/v1/auth/passwordless/request",
+		middleware.Chain(
+			http.HandlerFunc(handlers.RequestPasswordlessLogin),
+			middleware.RateLimit(rate.Every(20*time.Second), 3),
+		),
+	)
+	mux.Handle("POST /api/v1/auth/passwordless/verify",
+		middleware.Chain(
+			http.HandlerFunc(handlers.VerifyPasswordlessLogin),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
+
+	// Second step of login for accounts with two-factor authentication
+	mux.Handle("POST /api/v1/auth/login/mfa",
+		middleware.Chain(
+			http.HandlerFunc(handlers.LoginMFA),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
 
 
 
@@ -24,4 +51,98 @@ func RegisterAuthRoutes(mux *http.ServeMux) {
 			middleware.AuthMiddleware,
 		),
 	)
+
+	mux.Handle("POST /api/v1/auth/verify-email/resend",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ResendVerificationEmail),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/phone/verify/request",
+		middleware.Chain(
+			http.HandlerFunc(handlers.RequestPhoneVerification),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/phone/verify",
+		middleware.Chain(
+			http.HandlerFunc(handlers.VerifyPhone),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/password/change",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ChangePassword),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("GET /api/v1/auth/sessions",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ListSessions),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/auth/sessions",
+		middleware.Chain(
+			http.HandlerFunc(handlers.RevokeOtherSessions),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/auth/sessions/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.RevokeSession),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("GET /api/v1/auth/activity",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ListMyAuthEvents),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/mfa/totp/setup",
+		middleware.Chain(
+			http.HandlerFunc(handlers.SetupTOTP),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/mfa/totp/confirm",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ConfirmTOTP),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/mfa/totp/disable",
+		middleware.Chain(
+			http.HandlerFunc(handlers.DisableTOTP),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/mfa/recovery-codes",
+		middleware.Chain(
+			http.HandlerFunc(handlers.RegenerateRecoveryCodes),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
 }
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"golang.org/x/time/rate"
+)
+
+func RegisterOAuthRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /api/v1/auth/oauth/providers", middleware.Chain(http.HandlerFunc(handlers.ListOAuthProviders)))
+
+	mux.Handle("GET /api/v1/auth/oauth/{provider}/start",
+		middleware.Chain(
+			http.HandlerFunc(handlers.StartOAuthLogin),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/oauth/{provider}/callback",
+		middleware.Chain(
+			http.HandlerFunc(handlers.OAuthCallback),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
+}
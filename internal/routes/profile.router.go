@@ -12,6 +12,7 @@ func RegisterProfileRoutes(mux *http.ServeMux) {
 		middleware.Chain(
 			http.HandlerFunc(handlers.CompleteProfile),
 			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
 		),
 	)
 
@@ -22,10 +23,79 @@ func RegisterProfileRoutes(mux *http.ServeMux) {
 		),
 	)
 
+	mux.Handle("PATCH /api/v1/profile",
+		middleware.Chain(
+			http.HandlerFunc(handlers.PatchProfile),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
+
 	mux.Handle("GET /api/v1/profile/status",
 		middleware.Chain(
 			http.HandlerFunc(handlers.GetProfileStatus),
 			middleware.AuthMiddleware,
 		),
 	)
+
+	mux.Handle("GET /api/v1/profile/education/{level}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.GetEducation),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("PUT /api/v1/profile/education/{level}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.PutEducation),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/profile/education/{level}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.DeleteEducation),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
+
+	mux.Handle("GET /api/v1/profile/language-tests",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ListLanguageTests),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("POST /api/v1/profile/language-tests",
+		middleware.Chain(
+			http.HandlerFunc(handlers.CreateLanguageTest),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
+
+	mux.Handle("GET /api/v1/profile/language-tests/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.GetLanguageTest),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("PUT /api/v1/profile/language-tests/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.UpdateLanguageTest),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/profile/language-tests/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.DeleteLanguageTest),
+			middleware.AuthMiddleware,
+			middleware.RequireVerifiedEmail,
+		),
+	)
 }
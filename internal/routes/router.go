@@ -14,8 +14,13 @@ func RegisterRoutes() http.Handler {
 
 	// Attach different route groups
 	RegisterHealthRoutes(mux)
+	RegisterWellKnownRoutes(mux)
 	RegisterAuthRoutes(mux)
+	RegisterWebAuthnRoutes(mux)
+	RegisterOAuthRoutes(mux)
 	RegisterProfileRoutes(mux)
+	RegisterAccountRoutes(mux)
+	RegisterAdminRoutes(mux)
 	// RegisterUserRoutes(mux)
 
 	// Applied global middlewares (CORS, Logger, RateLimiter)
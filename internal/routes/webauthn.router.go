@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+	"github.com/MH-PAVEL/uni-backend-go/internal/middleware"
+	"golang.org/x/time/rate"
+)
+
+func RegisterWebAuthnRoutes(mux *http.ServeMux) {
+	// Public: passkey login
+	mux.Handle("POST /api/v1/auth/webauthn/login/begin",
+		middleware.Chain(
+			http.HandlerFunc(handlers.BeginPasskeyLogin),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/webauthn/login/finish",
+		middleware.Chain(
+			http.HandlerFunc(handlers.FinishPasskeyLogin),
+			middleware.RateLimit(rate.Every(6*time.Second), 10),
+		),
+	)
+
+	// Protected: passkey management
+	mux.Handle("POST /api/v1/auth/webauthn/register/begin",
+		middleware.Chain(
+			http.HandlerFunc(handlers.BeginPasskeyRegistration),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/webauthn/register/finish",
+		middleware.Chain(
+			http.HandlerFunc(handlers.FinishPasskeyRegistration),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+
+	mux.Handle("GET /api/v1/auth/webauthn/credentials",
+		middleware.Chain(
+			http.HandlerFunc(handlers.ListPasskeys),
+			middleware.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/auth/webauthn/credentials/{id}",
+		middleware.Chain(
+			http.HandlerFunc(handlers.DeletePasskey),
+			middleware.AuthMiddleware,
+			middleware.RejectImpersonation,
+		),
+	)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/handlers"
+)
+
+func RegisterWellKnownRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/jwks.json", handlers.JWKS)
+}
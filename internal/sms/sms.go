@@ -0,0 +1,125 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+)
+
+// Message is a text message to a phone number
+type Message struct {
+	To   string
+	Body string
+}
+
+// Provider delivers text messages
+type Provider interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the provider used by Send. It is replaced by Init on startup.
+var Default Provider = ConsoleProvider{}
+
+// Init selects the provider configured by SMS_PROVIDER
+func Init(cfg config.SMSConfig) error {
+	switch cfg.Provider {
+	case "", "console":
+		Default = ConsoleProvider{}
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0o755); err != nil {
+			return fmt.Errorf("failed to create sms directory: %w", err)
+		}
+		Default = &FileProvider{Path: cfg.FilePath}
+	case "http":
+		Default = HTTPProvider{
+			URL:    cfg.HTTPURL,
+			Token:  cfg.HTTPToken,
+			Sender: cfg.Sender,
+			Client: &http.Client{Timeout: 10 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown sms provider %q", cfg.Provider)
+	}
+	return nil
+}
+
+// Send delivers msg with the default provider
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// ConsoleProvider writes messages to the server log (development only)
+type ConsoleProvider struct{}
+
+func (ConsoleProvider) Send(_ context.Context, msg Message) error {
+	log.Printf("📱 SMS to %s: %s", msg.To, msg.Body)
+	return nil
+}
+
+// FileProvider appends messages to a file (development only)
+type FileProvider struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (p *FileProvider) Send(_ context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open sms file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), msg.To, msg.Body)
+	return err
+}
+
+// HTTPProvider posts messages as JSON to an SMS gateway
+type HTTPProvider struct {
+	URL    string
+	Token  string
+	Sender string
+	Client *http.Client
+}
+
+func (p HTTPProvider) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"from": p.Sender,
+		"to":   msg.To,
+		"text": msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms gateway returned %s", resp.Status)
+	}
+	return nil
+}
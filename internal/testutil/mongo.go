@@ -0,0 +1,93 @@
+// Package testutil sets up the shared state tests of the database backed
+// packages need: configuration, signing keys and a throwaway MongoDB
+// database.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/jwtkeys"
+	"github.com/MH-PAVEL/uni-backend-go/internal/mailer"
+	"github.com/MH-PAVEL/uni-backend-go/internal/password"
+	"github.com/MH-PAVEL/uni-backend-go/internal/sms"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoURIEnv names the variable pointing tests at a MongoDB server. Tests
+// needing the database are skipped when it is not set, except in CI, where
+// they fail so a missing server cannot pass unnoticed.
+const MongoURIEnv = "TEST_MONGO_URI"
+
+// Mongo loads a test configuration, connects to the server in
+// TEST_MONGO_URI and points the database package at a fresh database that
+// is dropped when the test ends. Tests using it must not run in parallel.
+func Mongo(t *testing.T) *config.Config {
+	t.Helper()
+
+	uri := os.Getenv(MongoURIEnv)
+	if uri == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s not set; CI must run the database tests", MongoURIEnv)
+		}
+		t.Skipf("%s not set", MongoURIEnv)
+	}
+
+	t.Setenv("MONGO_URI", uri)
+	t.Setenv("MONGO_DB_NAME", fmt.Sprintf("uni_test_%d", time.Now().UnixNano()))
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough-for-hs256")
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("MAIL_DRIVER", "log")
+	t.Setenv("SMS_PROVIDER", "console")
+	t.Setenv("BREACHED_PASSWORDS_FILE", "")
+	t.Setenv("OIDC_PROVIDERS", "")
+
+	previous := config.AppConfig
+	cfg := config.LoadConfig()
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	if err := jwtkeys.Init(cfg.Auth); err != nil {
+		t.Fatalf("jwtkeys: %v", err)
+	}
+	if err := mailer.Init(cfg.Mail); err != nil {
+		t.Fatalf("mailer: %v", err)
+	}
+	if err := sms.Init(cfg.SMS); err != nil {
+		t.Fatalf("sms: %v", err)
+	}
+	if err := password.Init(cfg.Auth); err != nil {
+		t.Fatalf("password policy: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect to MongoDB: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("ping MongoDB: %v", err)
+	}
+
+	previousClient := database.Client
+	database.Client = client
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = client.Database(cfg.Database.Name).Drop(ctx)
+		_ = client.Disconnect(ctx)
+		database.Client = previousClient
+	})
+
+	if err := database.CreateIndexes(); err != nil {
+		t.Fatalf("create indexes: %v", err)
+	}
+	return cfg
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StartAccountPurger runs PurgeDueAccounts every AccountPurgeInterval until
+// ctx is cancelled
+func StartAccountPurger(ctx context.Context) {
+	cfg := config.AppConfig
+	if cfg == nil || cfg.Auth.AccountPurgeInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Auth.AccountPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			n, err := PurgeDueAccounts(runCtx)
+			cancel()
+			if err != nil {
+				log.Printf("Account purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d deleted account(s)", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeDueAccounts purges every account whose deletion grace period is over
+// and returns how many were purged
+func PurgeDueAccounts(ctx context.Context) (int, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	cur, err := users.Find(ctx,
+		bson.M{"deletionScheduledAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find accounts to purge: %w", err)
+	}
+	var due []models.User
+	if err := cur.All(ctx, &due); err != nil {
+		return 0, fmt.Errorf("failed to find accounts to purge: %w", err)
+	}
+
+	purged := 0
+	for _, u := range due {
+		if err := purgeAccount(ctx, u.ID); err != nil {
+			log.Printf("Failed to purge account %s: %v", u.ID.Hex(), err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeLease is how long an account claimed by a purge is left to it
+// before a later run may take it over, e.g. after a crash
+const purgeLease = 15 * time.Minute
+
+// purgeAccount deletes the user and everything tied to them, and strips the
+// IP addresses and user agents from the audit records that are kept
+func purgeAccount(ctx context.Context, userID primitive.ObjectID) error {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	now := time.Now()
+
+	// Claimed before anything is touched: a claimed account can no longer
+	// be cancelled, and no other purge works on it at the same time
+	var user models.User
+	err := users.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":                 userID,
+			"deletionScheduledAt": bson.M{"$lte": now},
+			"$or": []bson.M{
+				{"purgingAt": bson.M{"$exists": false}},
+				{"purgingAt": bson.M{"$lt": now.Add(-purgeLease)}},
+			},
+		},
+		bson.M{"$set": bson.M{"purgingAt": now}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"email": 1, "phone": 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim user: %w", err)
+	}
+
+	// The user goes last, so a purge that fails half way is retried once
+	// its lease is over
+	if err := RevokeAllSessions(ctx, user.ID, models.RevocationReasonAccountDeleted); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	byUser := bson.M{"userId": user.ID}
+	for _, name := range []string{
+		database.UserTokensCollection,
+		database.WebAuthnCredentialsCollection,
+		database.WebAuthnChallengesCollection,
+	} {
+		if _, err := database.GetCollection(database.DbName(), name).DeleteMany(ctx, byUser); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+	}
+
+	targets := accountContacts(&user)
+
+	otps := database.GetCollection(database.DbName(), database.OTPCodesCollection)
+	_, err = otps.DeleteMany(ctx, bson.M{"$or": []bson.M{byUser, {"target": bson.M{"$in": targets}}}})
+	if err != nil {
+		return fmt.Errorf("failed to delete one-time codes: %w", err)
+	}
+
+	hashes := make([]string, 0, len(targets))
+	for _, t := range targets {
+		hashes = append(hashes, SHA256Hex(t))
+	}
+	attempts := database.GetCollection(database.DbName(), database.LoginAttemptsCollection)
+	if _, err := attempts.DeleteMany(ctx, bson.M{"identifierHash": bson.M{"$in": hashes}}); err != nil {
+		return fmt.Errorf("failed to delete login attempts: %w", err)
+	}
+
+	// Audit records stay, tied only to the now meaningless user id
+	mentions := AuditRecordsFilter(&user)
+	anonymise := bson.M{
+		"$set":   bson.M{"ip": "", "userAgent": ""},
+		"$unset": bson.M{"identifier": "", "details": ""},
+	}
+	for _, name := range []string{database.AuthEventsCollection, database.SecurityEventsCollection} {
+		if _, err := database.GetCollection(database.DbName(), name).UpdateMany(ctx, mentions, anonymise); err != nil {
+			return fmt.Errorf("failed to anonymise %s: %w", name, err)
+		}
+	}
+
+	if _, err := users.DeleteOne(ctx, bson.M{"_id": user.ID, "purgingAt": bson.M{"$exists": true}}); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	_ = RecordAuthEvent(ctx, models.AuthEvent{
+		Type:    models.AuthEventAccountDeleted,
+		Outcome: models.AuthOutcomeSuccess,
+		UserID:  &user.ID,
+	})
+	return nil
+}
+
+// AuditRecordsFilter matches the auth and security events about the user:
+// those with their id, failed logins and signups naming their email or
+// phone without an id, and the admin actions they took. Exporting and
+// purging an account both use it.
+func AuditRecordsFilter(user *models.User) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"userId": user.ID},
+		{"actorId": user.ID},
+		{"identifier": bson.M{"$in": accountContacts(user)}},
+	}}
+}
+
+// accountContacts returns the email and, if set, the phone of the user
+func accountContacts(user *models.User) []string {
+	contacts := []string{user.Email}
+	if user.Phone != "" {
+		contacts = append(contacts, user.Phone)
+	}
+	return contacts
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPurgeAccountClaim(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	insert := func(scheduledAt time.Time, purgingAt *time.Time) primitive.ObjectID {
+		t.Helper()
+		res, err := users.InsertOne(ctx, models.User{
+			Email:               primitive.NewObjectID().Hex() + "@example.com",
+			DeletionScheduledAt: &scheduledAt,
+			PurgingAt:           purgingAt,
+		})
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+		return res.InsertedID.(primitive.ObjectID)
+	}
+	exists := func(id primitive.ObjectID) bool {
+		t.Helper()
+		n, err := users.CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n > 0
+	}
+
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-2 * purgeLease)
+
+	for _, tc := range []struct {
+		name        string
+		scheduledAt time.Time
+		purgingAt   *time.Time
+		purged      bool
+	}{
+		{"due", time.Now().Add(-time.Hour), nil, true},
+		{"not due", time.Now().Add(time.Hour), nil, false},
+		{"claimed by another run", time.Now().Add(-time.Hour), &recent, false},
+		{"claim expired", time.Now().Add(-time.Hour), &stale, true},
+	} {
+		id := insert(tc.scheduledAt, tc.purgingAt)
+		if err := purgeAccount(ctx, id); err != nil {
+			t.Fatalf("%s: purgeAccount: %v", tc.name, err)
+		}
+		if got := !exists(id); got != tc.purged {
+			t.Errorf("%s: purged = %v, want %v", tc.name, got, tc.purged)
+		}
+	}
+}
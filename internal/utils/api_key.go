@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// APIKeyPrefix starts every API key so it can be told apart from a JWT
+	APIKeyPrefix = "uak_"
+	// apiKeyLastUsedInterval limits how often last-used tracking writes
+	apiKeyLastUsedInterval = time.Minute
+)
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// IsAPIKey reports whether token looks like an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// GenerateAPIKey returns a new key of the form uak_<prefix>_<secret> and its
+// prefix. The prefix is hex so it never contains the separator.
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b)
+
+	secret, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return APIKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+// AuthenticateAPIKey looks up an unrevoked, unexpired key and records its
+// use. Returns ErrInvalidAPIKey for anything else.
+func AuthenticateAPIKey(ctx context.Context, key, ip string) (*models.APIKey, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	keys := database.GetCollection(database.DbName(), database.APIKeysCollection)
+
+	var apiKey models.APIKey
+	err := keys.FindOne(ctx, bson.M{"prefix": prefix}).Decode(&apiKey)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(SHA256Hex(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Busy keys would otherwise write on every request
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyLastUsedInterval {
+		_, err := keys.UpdateOne(ctx,
+			bson.M{"_id": apiKey.ID},
+			bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+		apiKey.LastUsedAt = &now
+		apiKey.LastUsedIP = ip
+	}
+
+	return &apiKey, nil
+}
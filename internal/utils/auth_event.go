@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+)
+
+// NewAuthEvent starts an audit log entry with the client's IP and user agent
+func NewAuthEvent(r *http.Request, eventType, outcome string) models.AuthEvent {
+	return models.AuthEvent{
+		Type:      eventType,
+		Outcome:   outcome,
+		IP:        ClientIP(r),
+		UserAgent: clientUserAgent(r),
+	}
+}
+
+// RecordAuthEvent appends an event to the audit log. It is written even if
+// ctx has been cancelled. Failures are logged as well as returned so callers
+// can ignore them.
+func RecordAuthEvent(ctx context.Context, event models.AuthEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	events := database.GetCollection(database.DbName(), database.AuthEventsCollection)
+	if _, err := events.InsertOne(ctx, event); err != nil {
+		log.Printf("Failed to record auth event %q: %v", event.Type, err)
+		return fmt.Errorf("failed to record auth event: %w", err)
+	}
+	return nil
+}
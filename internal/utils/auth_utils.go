@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/MH-PAVEL/uni-backend-go/internal/config"
 	"github.com/MH-PAVEL/uni-backend-go/internal/database"
 	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -23,88 +24,101 @@ type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
-// IssueTokens creates a short-lived access JWT and a long-lived refresh token
-func IssueTokens(ctx context.Context, userID primitive.ObjectID) (access, refresh string, err error) {
-	cfg := config.AppConfig
-	if cfg == nil {
-		return "", "", fmt.Errorf("configuration not loaded")
-	}
-
-	// Generate access token
-	access, err = GenerateJWT(cfg.Auth.JWTSecret, userID.Hex(), cfg.Auth.AccessTTL)
+// IssueTokens starts a new session for the requesting device and returns
+// a short-lived access JWT and the session's long-lived refresh token
+func IssueTokens(ctx context.Context, r *http.Request, userID primitive.ObjectID) (access, refresh string, err error) {
+	session, refresh, err := CreateSession(ctx, r, userID)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Generate refresh token
-	refresh, err = GenerateSecureToken(32)
+	access, err = GenerateAccessToken(ctx, userID, session.ID)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Hash refresh token and store in user document
-	hash := SHA256Hex(refresh)
-	expiresAt := time.Now().Add(cfg.Auth.RefreshTTL)
+	return access, refresh, nil
+}
 
-	users := database.GetCollection(database.DbName(), database.UsersCollection)
-	_, err = users.UpdateOne(ctx, 
-		bson.M{"_id": userID},
-		bson.M{
-			"$set": bson.M{
-				"refreshTokenHash": hash,
-				"refreshTokenExpires": expiresAt,
-				"updatedAt": time.Now(),
-			},
-		},
-	)
+// GenerateAccessToken signs an access JWT for the user bound to the given
+// session and records its id on the session so it can be revoked with it
+func GenerateAccessToken(ctx context.Context, userID, sessionID primitive.ObjectID) (string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not loaded")
+	}
+
+	roles, perms, err := loadAccessGrants(ctx, userID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+		return "", err
 	}
 
-	return access, refresh, nil
+	claims := &AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.Hex()},
+		SessionID:        sessionID.Hex(),
+		Roles:            roles,
+		Permissions:      perms,
+	}
+	access, err := GenerateJWT(claims, cfg.Auth.AccessTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := trackAccessToken(ctx, sessionID, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return "", err
+	}
+	return access, nil
 }
 
-// ValidateRefreshToken validates a refresh token against the user's stored hash
-func ValidateRefreshToken(ctx context.Context, userID primitive.ObjectID, token string) (bool, error) {
-	users := database.GetCollection(database.DbName(), database.UsersCollection)
-	
-	var user models.User
-	err := users.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		return false, err
+// GenerateImpersonationToken signs a short-lived access token for userID
+// with adminID as the actor. It belongs to no session, so it cannot be
+// refreshed; it is recorded so that RevokeAllSessions for either account
+// puts it on the denylist.
+func GenerateImpersonationToken(ctx context.Context, adminID, userID primitive.ObjectID) (string, *AccessClaims, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", nil, fmt.Errorf("configuration not loaded")
 	}
 
-	// Check if token exists and is not expired
-	if user.RefreshTokenHash == "" || user.RefreshTokenExpires == nil {
-		return false, nil
+	roles, perms, err := loadAccessGrants(ctx, userID)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if time.Now().After(*user.RefreshTokenExpires) {
-		return false, nil
+	claims := &AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.Hex()},
+		Roles:            roles,
+		Permissions:      perms,
+		Act:              &Actor{Subject: adminID.Hex()},
+	}
+	access, err := GenerateJWT(claims, cfg.Auth.ImpersonationTTL)
+	if err != nil {
+		return "", nil, err
 	}
 
-	// Verify token hash
-	tokenHash := SHA256Hex(token)
-	return tokenHash == user.RefreshTokenHash, nil
+	if err := trackImpersonationToken(ctx, claims, adminID, userID); err != nil {
+		return "", nil, err
+	}
+	return access, claims, nil
 }
 
-// RevokeRefreshToken removes the refresh token from the user
-func RevokeRefreshToken(ctx context.Context, userID primitive.ObjectID) error {
+// loadAccessGrants returns the roles and extra permissions carried in the
+// user's access tokens. Suspended accounts get ErrAccountSuspended.
+func loadAccessGrants(ctx context.Context, userID primitive.ObjectID) ([]string, []string, error) {
 	users := database.GetCollection(database.DbName(), database.UsersCollection)
-	
-	_, err := users.UpdateOne(ctx,
+
+	var user models.User
+	err := users.FindOne(ctx,
 		bson.M{"_id": userID},
-		bson.M{
-			"$unset": bson.M{
-				"refreshTokenHash": "",
-				"refreshTokenExpires": "",
-			},
-			"$set": bson.M{
-				"updatedAt": time.Now(),
-			},
-		},
-	)
-	return err
+		options.FindOne().SetProjection(bson.M{"roles": 1, "permissions": 1, "suspended": 1}),
+	).Decode(&user)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load user roles: %w", err)
+	}
+	if user.Suspended {
+		return nil, nil, ErrAccountSuspended
+	}
+	return user.EffectiveRoles(), user.Permissions, nil
 }
 
 // SetRefreshCookie sets the refresh token as HttpOnly cookie.
@@ -128,15 +142,46 @@ func SetRefreshCookie(w http.ResponseWriter, token string) {
 
 // GetRefreshTokenFromReq reads refresh token from cookie first, then JSON body
 func GetRefreshTokenFromReq(r *http.Request) string {
+	token, _ := ReadRefreshToken(r)
+	return token
+}
+
+// ReadRefreshToken is GetRefreshTokenFromReq that also reports whether the
+// token came from the cookie, in which case the request needs a CSRF token
+func ReadRefreshToken(r *http.Request) (token string, fromCookie bool) {
 	if c, err := r.Cookie(RefreshTokenCookieName); err == nil && c.Value != "" {
-		return c.Value
+		return c.Value, true
 	}
 	var rr RefreshRequest
 	_ = SafeDecodeJSON(r, &rr)
-	return strings.TrimSpace(rr.RefreshToken)
+	return strings.TrimSpace(rr.RefreshToken), false
+}
+
+// GetAccessTokenFromReq reads the access token from the Authorization header
+// (Bearer, case-insensitive) first, then the access token cookie
+func GetAccessTokenFromReq(r *http.Request) string {
+	token, _ := ReadAccessToken(r)
+	return token
 }
 
-// SetAccessCookie sets the access token as HttpOnly cookie
+// ReadAccessToken is GetAccessTokenFromReq that also reports whether the
+// token came from the cookie, in which case unsafe requests need a CSRF token
+func ReadAccessToken(r *http.Request) (token string, fromCookie bool) {
+	h := strings.TrimSpace(r.Header.Get("Authorization"))
+	if h != "" {
+		parts := strings.Fields(h)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return parts[1], false
+		}
+	}
+	if c, err := r.Cookie(AccessTokenCookieName); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	return "", false
+}
+
+// SetAccessCookie sets the access token as HttpOnly cookie, with a fresh
+// CSRF token next to it
 func SetAccessCookie(w http.ResponseWriter, token string) {
 	cfg := config.AppConfig
 	if cfg == nil {
@@ -152,4 +197,31 @@ func SetAccessCookie(w http.ResponseWriter, token string) {
 		Path:     "/",
 		MaxAge:   int(cfg.Auth.AccessTTL.Seconds()),
 	})
-}
\ No newline at end of file
+	setCSRFCookie(w)
+}
+
+// ClearAuthCookies expires the access, refresh and CSRF cookies
+func ClearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshTokenCookieName,
+		Value:    "",
+		Path:     "/api/v1/auth",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     AccessTokenCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   CSRFCookieName,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+}
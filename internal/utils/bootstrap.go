@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/password"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BootstrapAdmin makes BOOTSTRAP_ADMIN_EMAIL an admin while no admin exists.
+// An existing account is promoted; otherwise one is created with
+// BOOTSTRAP_ADMIN_PASSWORD. Once any admin exists this does nothing, so
+// further admins are granted through the admin API.
+func BootstrapAdmin(ctx context.Context) error {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+	email := cfg.Auth.BootstrapAdminEmail
+	if email == "" {
+		return nil
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	n, err := users.CountDocuments(ctx, bson.M{"roles": models.RoleAdmin})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	res, err := users.UpdateOne(ctx,
+		bson.M{"email": email},
+		bson.M{
+			"$addToSet": bson.M{"roles": models.RoleAdmin},
+			"$set":      bson.M{"updatedAt": now},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		log.Printf("Granted admin role to %s", email)
+		return nil
+	}
+
+	if cfg.Auth.BootstrapAdminPassword == "" {
+		return errors.New("no account for BOOTSTRAP_ADMIN_EMAIL; set BOOTSTRAP_ADMIN_PASSWORD to create it")
+	}
+	if err := password.Validate(cfg.Auth.BootstrapAdminPassword, email); err != nil {
+		return fmt.Errorf("BOOTSTRAP_ADMIN_PASSWORD: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(cfg.Auth.BootstrapAdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// The operator chose this address, so it counts as verified
+	_, err = users.InsertOne(ctx, models.User{
+		Email:           email,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+		Password:        string(hash),
+		Roles:           []string{models.RoleAdmin},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Created concurrently by another instance
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Created admin account %s", email)
+	return nil
+}
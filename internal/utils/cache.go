@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+)
+
+// flagCache remembers yes/no lookups until a per-entry deadline so hot
+// checks (revoked token, suspended user) do not hit the database on every
+// request. When full, expired entries and then "no" answers are dropped:
+// "yes" answers are the ones worth keeping. If only "yes" answers are left,
+// the one closest to expiry goes; a forgotten answer is simply looked up
+// again.
+type flagCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]flagEntry
+}
+
+type flagEntry struct {
+	value bool
+	until time.Time
+}
+
+func newFlagCache(max int) *flagCache {
+	return &flagCache{max: max, entries: map[string]flagEntry{}}
+}
+
+// get returns the cached answer for key, if there is an unexpired one
+func (c *flagCache) get(key string) (value, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, found := c.entries[key]
+	if !found || !time.Now().Before(e.until) {
+		return false, false
+	}
+	return e.value, true
+}
+
+func (c *flagCache) set(key string, value bool, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.entries[key]; !found && len(c.entries) >= c.max {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.until) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.max {
+			for k, e := range c.entries {
+				if !e.value {
+					delete(c.entries, k)
+				}
+			}
+		}
+		if len(c.entries) >= c.max {
+			var oldest string
+			var oldestUntil time.Time
+			for k, e := range c.entries {
+				if oldestUntil.IsZero() || e.until.Before(oldestUntil) {
+					oldest, oldestUntil = k, e.until
+				}
+			}
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = flagEntry{value: value, until: until}
+}
+
+// negativeCacheTTL is how long a "no" answer may be cached
+func negativeCacheTTL() time.Duration {
+	if cfg := config.AppConfig; cfg != nil {
+		return cfg.Auth.RevocationCacheTTL
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFlagCacheStaysBounded(t *testing.T) {
+	c := newFlagCache(10)
+	until := time.Now().Add(time.Hour)
+
+	// Only "yes" answers, none expired: nothing can be dropped for free
+	for i := range 100 {
+		c.set(strconv.Itoa(i), true, until.Add(time.Duration(i)*time.Second))
+		if len(c.entries) > c.max {
+			t.Fatalf("after %d sets: %d entries, want at most %d", i+1, len(c.entries), c.max)
+		}
+	}
+
+	// The entries closest to expiry went first
+	if _, ok := c.get("0"); ok {
+		t.Error("oldest entry still cached")
+	}
+	if v, ok := c.get("99"); !ok || !v {
+		t.Errorf("newest entry = %v, %v; want true, true", v, ok)
+	}
+
+	// Replacing a cached entry drops nothing
+	c.set("99", true, until)
+	if len(c.entries) != c.max {
+		t.Errorf("%d entries after replacing one, want %d", len(c.entries), c.max)
+	}
+}
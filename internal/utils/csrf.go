@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+)
+
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// setCSRFCookie issues a new double-submit CSRF token. The cookie is
+// readable by the frontend, which echoes it in the X-CSRF-Token header; the
+// token is also sent in that response header for clients on another domain.
+// It lives as long as the refresh token so refreshing keeps working.
+func setCSRFCookie(w http.ResponseWriter) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return
+	}
+
+	token, err := GenerateSecureToken(32)
+	if err != nil {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		HttpOnly: false,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false,
+		Path:     "/",
+		MaxAge:   int(cfg.Auth.RefreshTTL.Seconds()),
+	})
+	w.Header().Set(CSRFHeaderName, token)
+}
+
+// IsUnsafeMethod reports whether the method can change state and so needs
+// CSRF protection
+func IsUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// ValidCSRF reports whether the X-CSRF-Token header matches the CSRF cookie
+func ValidCSRF(r *http.Request) bool {
+	c, err := r.Cookie(CSRFCookieName)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	h := r.Header.Get(CSRFHeaderName)
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(h)) == 1
+}
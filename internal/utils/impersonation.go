@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trackImpersonationToken records an impersonation token so that signing
+// out the admin or the user revokes it
+func trackImpersonationToken(ctx context.Context, claims *AccessClaims, adminID, userID primitive.ObjectID) error {
+	tokens := database.GetCollection(database.DbName(), database.ImpersonationTokensCollection)
+	_, err := tokens.InsertOne(ctx, models.ImpersonationToken{
+		JTI:       claims.ID,
+		UserID:    userID,
+		ActorID:   adminID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// revokeImpersonationTokens denylists every impersonation token issued to
+// or for the user
+func revokeImpersonationTokens(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	tokens := database.GetCollection(database.DbName(), database.ImpersonationTokensCollection)
+	filter := bson.M{"$or": []bson.M{{"userId": userID}, {"actorId": userID}}}
+
+	cur, err := tokens.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	var found []models.ImpersonationToken
+	if err := cur.All(ctx, &found); err != nil {
+		return err
+	}
+
+	for _, t := range found {
+		if err := RevokeAccessToken(ctx, t.JTI, t.UserID, t.ExpiresAt, reason); err != nil {
+			return err
+		}
+	}
+	_, err = tokens.DeleteMany(ctx, filter)
+	return err
+}
+
+// CanImpersonate reports whether the account may still act as other users:
+// it exists, is not suspended and has the admin role. It is checked on
+// every impersonated request, so it is not cached.
+func CanImpersonate(ctx context.Context, adminID primitive.ObjectID) (bool, error) {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	var admin models.User
+	err := users.FindOne(ctx,
+		bson.M{"_id": adminID},
+		options.FindOne().SetProjection(bson.M{"roles": 1, "suspended": 1}),
+	).Decode(&admin)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !admin.Suspended && slices.Contains(admin.EffectiveRoles(), models.RoleAdmin), nil
+}
@@ -1,18 +1,108 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/jwtkeys"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GenerateJWT(secret, userID string, ttl time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"_id": userID,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(ttl).Unix(),
+// AccessClaims are the claims of an access token. Subject is the user id.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	SessionID   string   `json:"sid,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"` // granted on top of the roles
+	Scope       string   `json:"scope,omitempty"` // space-separated
+	Act         *Actor   `json:"act,omitempty"`   // set when an admin impersonates the subject
+}
+
+// Actor is the act claim (RFC 8693): who is really using a token issued
+// for someone else
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// UserID returns the subject as an ObjectID
+func (c *AccessClaims) UserID() (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(c.Subject)
+}
+
+// Impersonated reports whether the token was issued to an admin acting as
+// the subject
+func (c *AccessClaims) Impersonated() bool {
+	return c.Act != nil && c.Act.Subject != ""
+}
+
+// HasRole reports whether the token carries role
+func (c *AccessClaims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasPermission reports whether the token's roles or extra grants include perm
+func (c *AccessClaims) HasPermission(perm string) bool {
+	return models.HasPermission(c.Roles, c.Permissions, perm)
+}
+
+// HasScope reports whether the token carries scope
+func (c *AccessClaims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// GenerateJWT signs an access token. The caller fills in the subject,
+// session, roles and scope; issuer, audience, times and jti are set here
+// and left on claims.
+func GenerateJWT(claims *AccessClaims, ttl time.Duration) (string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not loaded")
+	}
+
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+
+	now := time.Now()
+	claims.Issuer = cfg.Auth.JWTIssuer
+	claims.Audience = jwt.ClaimStrings{cfg.Auth.JWTAudience}
+	claims.ID = jti
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	return jwtkeys.Sign(claims)
 }
 
+// ParseAccessToken verifies an access token's signature, issuer, audience
+// and validity window and returns its claims
+func ParseAccessToken(raw string) (*AccessClaims, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration not loaded")
+	}
+
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, jwtkeys.Keyfunc,
+		jwt.WithValidMethods(jwtkeys.Methods()),
+		jwt.WithIssuer(cfg.Auth.JWTIssuer),
+		jwt.WithAudience(cfg.Auth.JWTAudience),
+		jwt.WithLeeway(cfg.Auth.JWTLeeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, errors.New("token is missing sub or jti")
+	}
+	return claims, nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginLockedUntil returns when the lockout of identifier ends, or the zero
+// time if it is not locked
+func LoginLockedUntil(ctx context.Context, identifier string) (time.Time, error) {
+	attempts := database.GetCollection(database.DbName(), database.LoginAttemptsCollection)
+
+	var attempt models.LoginAttempt
+	err := attempts.FindOne(ctx, bson.M{
+		"identifierHash": SHA256Hex(identifier),
+		"lockedUntil":    bson.M{"$gt": time.Now()},
+	}).Decode(&attempt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *attempt.LockedUntil, nil
+}
+
+// RecordLoginFailure counts a failed login for identifier. Once the limit is
+// reached the identifier is locked, for twice as long with every further
+// failure. Returns the end of the lockout (zero if not locked) and whether
+// this failure started the first lockout of the window.
+func RecordLoginFailure(ctx context.Context, identifier, ip string) (time.Time, bool, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return time.Time{}, false, fmt.Errorf("configuration not loaded")
+	}
+
+	now := time.Now()
+	attempts := database.GetCollection(database.DbName(), database.LoginAttemptsCollection)
+
+	var attempt models.LoginAttempt
+	err := attempts.FindOneAndUpdate(ctx,
+		bson.M{"identifierHash": SHA256Hex(identifier)},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{
+				"lastIp":        ip,
+				"lastFailureAt": now,
+				"expiresAt":     now.Add(cfg.Auth.LoginFailureWindow),
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	over := attempt.Failures - cfg.Auth.LoginMaxFailures
+	if cfg.Auth.LoginMaxFailures <= 0 || over < 0 {
+		return time.Time{}, false, nil
+	}
+
+	lockout := cfg.Auth.LoginLockoutMax
+	if over < 30 {
+		lockout = min(cfg.Auth.LoginLockoutBase<<over, cfg.Auth.LoginLockoutMax)
+	}
+	lockedUntil := now.Add(lockout)
+
+	// Keep the lock at least until the end of the lockout, and the counter
+	// at least that long too
+	_, err = attempts.UpdateOne(ctx,
+		bson.M{"_id": attempt.ID},
+		bson.M{"$max": bson.M{
+			"lockedUntil": lockedUntil,
+			"expiresAt":   lockedUntil.Add(cfg.Auth.LoginFailureWindow),
+		}},
+	)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to lock login: %w", err)
+	}
+
+	return lockedUntil, over == 0, nil
+}
+
+// ClearLoginFailures resets the failure counter of identifier after a
+// successful login
+func ClearLoginFailures(ctx context.Context, identifier string) error {
+	attempts := database.GetCollection(database.DbName(), database.LoginAttemptsCollection)
+	_, err := attempts.DeleteOne(ctx, bson.M{"identifierHash": SHA256Hex(identifier)})
+	return err
+}
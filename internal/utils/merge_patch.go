@@ -0,0 +1,30 @@
+package utils
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to target. Both are
+// decoded JSON values (map[string]any, []any, string, float64, bool or nil).
+// Objects are merged key by key, null removes a key and anything else,
+// arrays included, replaces the target value.
+func MergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	result := make(map[string]any, len(targetObj))
+	for k, v := range targetObj {
+		result[k] = v
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = MergePatch(result[k], v)
+	}
+	return result
+}
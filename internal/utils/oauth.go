@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/oidc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidOAuthState = errors.New("unknown or expired oauth state")
+
+// BeginOAuthLogin stores a new authorization request for provider and
+// returns the state plus the values the authorization URL needs
+func BeginOAuthLogin(ctx context.Context, provider string) (state, nonce, codeChallenge string, err error) {
+	state, err = oidc.RandomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	nonce, err = oidc.RandomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	verifier, codeChallenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	now := time.Now()
+	doc := models.OAuthState{
+		StateHash:    SHA256Hex(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(config.AppConfig.OAuth.StateTTL),
+		CreatedAt:    now,
+	}
+
+	states := database.GetCollection(database.DbName(), database.OAuthStatesCollection)
+	if _, err := states.InsertOne(ctx, doc); err != nil {
+		return "", "", "", fmt.Errorf("failed to store oauth state: %w", err)
+	}
+	return state, nonce, codeChallenge, nil
+}
+
+// TakeOAuthState finds and deletes the authorization request for state, so
+// a callback can only be completed once
+func TakeOAuthState(ctx context.Context, provider, state string) (*models.OAuthState, error) {
+	states := database.GetCollection(database.DbName(), database.OAuthStatesCollection)
+	filter := bson.M{
+		"stateHash": SHA256Hex(state),
+		"provider":  provider,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var stored models.OAuthState
+	err := states.FindOneAndDelete(ctx, filter).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidOAuthState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load oauth state: %w", err)
+	}
+	return &stored, nil
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOTPCooldown        = errors.New("a code was sent recently")
+	ErrInvalidOTP         = errors.New("invalid or expired code")
+	ErrOTPTooManyAttempts = errors.New("too many attempts")
+)
+
+// GenerateNumericCode returns a uniformly random string of n decimal digits
+func GenerateNumericCode(n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + d.Int64()))
+	}
+	return b.String(), nil
+}
+
+// IssueOTP creates a new code for target, replacing any earlier one for the
+// same purpose. Returns ErrOTPCooldown if the previous code is too recent.
+func IssueOTP(ctx context.Context, purpose, target string, userID *primitive.ObjectID) (string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not loaded")
+	}
+
+	codes := database.GetCollection(database.DbName(), database.OTPCodesCollection)
+	filter := bson.M{"purpose": purpose, "target": target}
+
+	var existing models.OTPCode
+	err := codes.FindOne(ctx, filter).Decode(&existing)
+	if err == nil && time.Since(existing.CreatedAt) < cfg.Auth.OTPResendCooldown {
+		return "", ErrOTPCooldown
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("failed to look up code: %w", err)
+	}
+
+	code, err := GenerateNumericCode(cfg.Auth.OTPLength)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = codes.ReplaceOne(ctx, filter, models.OTPCode{
+		Purpose:     purpose,
+		Target:      target,
+		UserID:      userID,
+		CodeHash:    hashOTP(cfg.Auth.JWTSecret, purpose, target, code),
+		Attempts:    0,
+		MaxAttempts: cfg.Auth.OTPMaxAttempts,
+		ExpiresAt:   now.Add(cfg.Auth.OTPTTL),
+		CreatedAt:   now,
+	}, options.Replace().SetUpsert(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to store code: %w", err)
+	}
+
+	return code, nil
+}
+
+// VerifyOTP checks code for target and uses it up on success. Every guess
+// counts against the code before it is compared, so parallel guesses cannot
+// share one attempt; once the limit is reached the code is discarded and
+// ErrOTPTooManyAttempts is returned.
+func VerifyOTP(ctx context.Context, purpose, target, code string) (*models.OTPCode, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration not loaded")
+	}
+
+	codes := database.GetCollection(database.DbName(), database.OTPCodesCollection)
+	filter := bson.M{
+		"purpose":   purpose,
+		"target":    target,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var stored models.OTPCode
+	err := codes.FindOneAndUpdate(ctx,
+		bson.M{"$and": bson.A{
+			filter,
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$attempts", "$maxAttempts"}}},
+		}},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either there is no code or its attempts are used up
+		res, err := codes.DeleteOne(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete code: %w", err)
+		}
+		if res.DeletedCount > 0 {
+			return nil, ErrOTPTooManyAttempts
+		}
+		return nil, ErrInvalidOTP
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	expected := hashOTP(cfg.Auth.JWTSecret, purpose, target, strings.TrimSpace(code))
+	if !hmac.Equal([]byte(expected), []byte(stored.CodeHash)) {
+		if stored.Attempts >= stored.MaxAttempts {
+			_, _ = codes.DeleteOne(ctx, bson.M{"_id": stored.ID})
+			return nil, ErrOTPTooManyAttempts
+		}
+		return nil, ErrInvalidOTP
+	}
+
+	// Single use: only the request that deletes the code wins
+	res, err := codes.DeleteOne(ctx, bson.M{"_id": stored.ID, "codeHash": stored.CodeHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume code: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return nil, ErrInvalidOTP
+	}
+
+	return &stored, nil
+}
+
+// hashOTP keys the hash with the app secret: short numeric codes would be
+// trivial to brute-force from a plain hash
+func hashOTP(secret, purpose, target, code string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + target + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// revocationCache remembers denylist lookups. Revoked entries are kept
+// until the token expires; negative answers only for RevocationCacheTTL.
+var revocationCache = newFlagCache(10000)
+
+// IsAccessTokenRevoked reports whether the access token with the given id
+// is on the denylist
+func IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if revoked, ok := revocationCache.get(jti); ok {
+		return revoked, nil
+	}
+
+	revoked := database.GetCollection(database.DbName(), database.RevokedTokensCollection)
+	var doc models.RevokedToken
+	err := revoked.FindOne(ctx, bson.M{"jti": jti}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if ttl := negativeCacheTTL(); ttl > 0 {
+			revocationCache.set(jti, false, time.Now().Add(ttl))
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	revocationCache.set(jti, true, doc.ExpiresAt)
+	return true, nil
+}
+
+// RevokeAccessToken puts an access token on the denylist until it expires
+func RevokeAccessToken(ctx context.Context, jti string, userID primitive.ObjectID, expiresAt time.Time, reason string) error {
+	if jti == "" || !time.Now().Before(expiresAt) {
+		return nil
+	}
+
+	revoked := database.GetCollection(database.DbName(), database.RevokedTokensCollection)
+	_, err := revoked.UpdateOne(ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			JTI:       jti,
+			UserID:    userID,
+			Reason:    reason,
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	revocationCache.set(jti, true, expiresAt)
+	return nil
+}
+
+// revokeSessionAccessTokens denylists every unexpired access token issued
+// for the given sessions
+func revokeSessionAccessTokens(ctx context.Context, sessions []models.Session, reason string) error {
+	for _, s := range sessions {
+		for _, t := range s.AccessTokens {
+			if err := RevokeAccessToken(ctx, t.JTI, s.UserID, t.ExpiresAt, reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
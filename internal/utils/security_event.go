@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+)
+
+// RecordSecurityEvent stores a security event. Failures are logged as well as
+// returned so callers on an error path can ignore them.
+func RecordSecurityEvent(ctx context.Context, event models.SecurityEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	events := database.GetCollection(database.DbName(), database.SecurityEventsCollection)
+	if _, err := events.InsertOne(ctx, event); err != nil {
+		log.Printf("Failed to record security event %q: %v", event.Type, err)
+		return fmt.Errorf("failed to record security event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxUserAgentLength caps how much of the User-Agent header is stored per session
+	maxUserAgentLength = 512
+	// maxSpentTokenHashes caps how many rotated refresh tokens a session remembers
+	maxSpentTokenHashes = 200
+	// maxAccessTokens caps how many unexpired access token ids a session remembers
+	maxAccessTokens = 100
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// ClientIP returns the IP address of the remote peer
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func clientUserAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return ua
+}
+
+// CreateSession stores a new session for the requesting device and returns its refresh token
+func CreateSession(ctx context.Context, r *http.Request, userID primitive.ObjectID) (*models.Session, string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return nil, "", fmt.Errorf("configuration not loaded")
+	}
+
+	refresh, err := GenerateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:           userID,
+		RefreshTokenHash: SHA256Hex(refresh),
+		UserAgent:        clientUserAgent(r),
+		IP:               ClientIP(r),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(cfg.Auth.RefreshTTL),
+	}
+
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+	res, err := sessions.InsertOne(ctx, session)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store session: %w", err)
+	}
+	session.ID = res.InsertedID.(primitive.ObjectID)
+
+	return session, refresh, nil
+}
+
+// RotateSession replaces the session's refresh token with a new one and
+// remembers the old token as spent. Returns ErrSessionNotFound when the token
+// is unknown or expired. Presenting a spent token revokes the whole session
+// (token family), records a security event and returns ErrRefreshTokenReused
+// along with the revoked session.
+func RotateSession(ctx context.Context, r *http.Request, refreshToken string) (*models.Session, string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return nil, "", fmt.Errorf("configuration not loaded")
+	}
+
+	newRefresh, err := GenerateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	tokenHash := SHA256Hex(refreshToken)
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+
+	// Matching on the current hash makes rotation atomic: of two concurrent
+	// requests with the same token only one wins, the other sees a spent token.
+	var session models.Session
+	err = sessions.FindOneAndUpdate(ctx,
+		bson.M{
+			"refreshTokenHash": tokenHash,
+			"expiresAt":        bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"refreshTokenHash": SHA256Hex(newRefresh),
+				"userAgent":        clientUserAgent(r),
+				"ip":               ClientIP(r),
+				"lastUsedAt":       now,
+				"expiresAt":        now.Add(cfg.Auth.RefreshTTL),
+			},
+			"$push": bson.M{
+				"spentTokenHashes": bson.M{
+					"$each":  bson.A{tokenHash},
+					"$slice": -maxSpentTokenHashes,
+				},
+			},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err == nil {
+		return &session, newRefresh, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, "", fmt.Errorf("failed to rotate session: %w", err)
+	}
+
+	// Not a current token: check whether it was already rotated
+	var family models.Session
+	err = sessions.FindOneAndDelete(ctx, bson.M{"spentTokenHashes": tokenHash}).Decode(&family)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, "", ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check refresh token reuse: %w", err)
+	}
+
+	_ = revokeSessionAccessTokens(ctx, []models.Session{family}, models.RevocationReasonTokenReuse)
+
+	_ = RecordSecurityEvent(ctx, models.SecurityEvent{
+		Type:      models.SecurityEventRefreshTokenReuse,
+		UserID:    &family.UserID,
+		SessionID: &family.ID,
+		IP:        ClientIP(r),
+		UserAgent: clientUserAgent(r),
+		Details:   "spent refresh token presented; session revoked",
+	})
+
+	return &family, "", ErrRefreshTokenReused
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func ListSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+
+	cur, err := sessions.Find(ctx,
+		bson.M{"userId": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.Session{}
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// trackAccessToken remembers an access token issued for the session,
+// dropping the ids of tokens that have expired since
+func trackAccessToken(ctx context.Context, sessionID primitive.ObjectID, jti string, expiresAt time.Time) error {
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+	_, err := sessions.UpdateOne(ctx, bson.M{"_id": sessionID}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"accessTokens": bson.M{"$slice": bson.A{
+				bson.M{"$concatArrays": bson.A{
+					bson.M{"$filter": bson.M{
+						"input": bson.M{"$ifNull": bson.A{"$accessTokens", bson.A{}}},
+						"cond":  bson.M{"$gt": bson.A{"$$this.expiresAt", time.Now()}},
+					}},
+					bson.A{models.IssuedToken{JTI: jti, ExpiresAt: expiresAt}},
+				}},
+				-maxAccessTokens,
+			}},
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record access token: %w", err)
+	}
+	return nil
+}
+
+// deleteSessions deletes the sessions matching filter and revokes the
+// access tokens issued for them
+func deleteSessions(ctx context.Context, filter bson.M, reason string) (int64, error) {
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+
+	cur, err := sessions.Find(ctx, filter, options.Find().SetProjection(bson.M{"userId": 1, "accessTokens": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var found []models.Session
+	if err := cur.All(ctx, &found); err != nil {
+		return 0, err
+	}
+
+	res, err := sessions.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := revokeSessionAccessTokens(ctx, found, reason); err != nil {
+		return res.DeletedCount, err
+	}
+	return res.DeletedCount, nil
+}
+
+// RevokeSessionByRefreshToken deletes the session that owns the given
+// refresh token and returns it. Returns ErrSessionNotFound when the token is
+// unknown.
+func RevokeSessionByRefreshToken(ctx context.Context, refreshToken string) (*models.Session, error) {
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+
+	var session models.Session
+	err := sessions.FindOne(ctx,
+		bson.M{"refreshTokenHash": SHA256Hex(refreshToken)},
+		options.FindOne().SetProjection(bson.M{"userId": 1}),
+	).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := deleteSessions(ctx, bson.M{"_id": session.ID}, models.RevocationReasonLogout); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RevokeSession deletes one of the user's sessions
+func RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	n, err := deleteSessions(ctx, bson.M{"_id": sessionID, "userId": userID}, models.RevocationReasonSessionRevoked)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions deletes every session of the user except keepID
+func RevokeOtherSessions(ctx context.Context, userID, keepID primitive.ObjectID) (int64, error) {
+	return deleteSessions(ctx, bson.M{"userId": userID, "_id": bson.M{"$ne": keepID}}, models.RevocationReasonSessionRevoked)
+}
+
+// RevokeAllSessions deletes every session of the user and revokes their
+// access tokens, recording reason on the denylist
+func RevokeAllSessions(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	if _, err := deleteSessions(ctx, bson.M{"userId": userID}, reason); err != nil {
+		return err
+	}
+	return revokeImpersonationTokens(ctx, userID, reason)
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestSession stores a user with one session and an access token issued
+// for it, and returns the session's refresh token
+func newTestSession(t *testing.T) (models.Session, string) {
+	t.Helper()
+	ctx := context.Background()
+	r := httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	res, err := users.InsertOne(ctx, models.User{
+		Email:     primitive.NewObjectID().Hex() + "@example.com",
+		Roles:     []string{models.RoleStudent},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	session, refresh, err := CreateSession(ctx, r, res.InsertedID.(primitive.ObjectID))
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := GenerateAccessToken(ctx, session.UserID, session.ID); err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return *session, refresh
+}
+
+func findTestSession(t *testing.T, id primitive.ObjectID) (models.Session, bool) {
+	t.Helper()
+	var session models.Session
+	sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+	err := sessions.FindOne(context.Background(), bson.M{"_id": id}).Decode(&session)
+	if err != nil {
+		return session, false
+	}
+	return session, true
+}
+
+func TestRotateSession(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+	r := httptest.NewRequest("POST", "/api/v1/auth/refresh", nil)
+
+	t.Run("returns a new refresh token", func(t *testing.T) {
+		session, refresh := newTestSession(t)
+
+		rotated, next, err := RotateSession(ctx, r, refresh)
+		if err != nil {
+			t.Fatalf("RotateSession: %v", err)
+		}
+		if next == "" || next == refresh {
+			t.Fatal("refresh token not rotated")
+		}
+		if rotated.ID != session.ID || rotated.UserID != session.UserID {
+			t.Fatal("rotation moved the token to another session")
+		}
+		if rotated.RefreshTokenHash != SHA256Hex(next) {
+			t.Fatal("session does not hold the new token")
+		}
+
+		if _, again, err := RotateSession(ctx, r, next); err != nil || again == next {
+			t.Fatalf("rotating the new token: err = %v", err)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		if _, _, err := RotateSession(ctx, r, "not-a-token"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("err = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		session, refresh := newTestSession(t)
+		sessions := database.GetCollection(database.DbName(), database.SessionsCollection)
+		if _, err := sessions.UpdateOne(ctx, bson.M{"_id": session.ID},
+			bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Minute)}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := RotateSession(ctx, r, refresh); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("err = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("reusing a rotated token revokes the session", func(t *testing.T) {
+		session, refresh := newTestSession(t)
+		stored, _ := findTestSession(t, session.ID)
+		if len(stored.AccessTokens) != 1 {
+			t.Fatalf("session tracks %d access tokens, want 1", len(stored.AccessTokens))
+		}
+		jti := stored.AccessTokens[0].JTI
+
+		_, next, err := RotateSession(ctx, r, refresh)
+		if err != nil {
+			t.Fatalf("RotateSession: %v", err)
+		}
+
+		reused, _, err := RotateSession(ctx, r, refresh)
+		if !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("err = %v, want %v", err, ErrRefreshTokenReused)
+		}
+		if reused == nil || reused.ID != session.ID || reused.UserID != session.UserID {
+			t.Fatalf("reused session = %+v, want %s of user %s", reused, session.ID.Hex(), session.UserID.Hex())
+		}
+		if _, ok := findTestSession(t, session.ID); ok {
+			t.Fatal("session kept after its refresh token was reused")
+		}
+		if _, _, err := RotateSession(ctx, r, next); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("current token of a revoked session: err = %v, want %v", err, ErrSessionNotFound)
+		}
+		if revoked, err := IsAccessTokenRevoked(ctx, jti); err != nil || !revoked {
+			t.Fatalf("access token of the revoked session: revoked = %t, err = %v", revoked, err)
+		}
+
+		events := database.GetCollection(database.DbName(), database.SecurityEventsCollection)
+		n, err := events.CountDocuments(ctx, bson.M{"type": models.SecurityEventRefreshTokenReuse, "sessionId": session.ID})
+		if err != nil || n != 1 {
+			t.Fatalf("%d reuse events recorded, want 1 (err = %v)", n, err)
+		}
+	})
+
+	t.Run("concurrent rotations have one winner", func(t *testing.T) {
+		_, refresh := newTestSession(t)
+
+		const workers = 8
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		start := make(chan struct{})
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, _, err := RotateSession(ctx, r, refresh)
+				errs <- err
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+
+		winners := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				winners++
+			case errors.Is(err, ErrRefreshTokenReused), errors.Is(err, ErrSessionNotFound):
+			default:
+				t.Fatalf("RotateSession: %v", err)
+			}
+		}
+		if winners != 1 {
+			t.Fatalf("%d rotations succeeded, want exactly 1", winners)
+		}
+	})
+}
+
+func TestRevokeAllSessionsRevokesImpersonation(t *testing.T) {
+	testutil.Mongo(t)
+	ctx := context.Background()
+
+	for _, who := range []string{"admin", "user"} {
+		t.Run("signing out the "+who, func(t *testing.T) {
+			admin, _ := newTestSession(t)
+			user, _ := newTestSession(t)
+
+			_, claims, err := GenerateImpersonationToken(ctx, admin.UserID, user.UserID)
+			if err != nil {
+				t.Fatalf("GenerateImpersonationToken: %v", err)
+			}
+			if revoked, err := IsAccessTokenRevoked(ctx, claims.ID); err != nil || revoked {
+				t.Fatalf("fresh token: revoked = %t, err = %v", revoked, err)
+			}
+
+			signedOut := admin.UserID
+			if who == "user" {
+				signedOut = user.UserID
+			}
+			if err := RevokeAllSessions(ctx, signedOut, models.RevocationReasonAdmin); err != nil {
+				t.Fatalf("RevokeAllSessions: %v", err)
+			}
+			if revoked, err := IsAccessTokenRevoked(ctx, claims.ID); err != nil || !revoked {
+				t.Fatalf("revoked = %t, err = %v", revoked, err)
+			}
+		})
+	}
+}
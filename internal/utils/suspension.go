@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAccountSuspended = errors.New("account suspended")
+
+// suspensionCache remembers suspension lookups for RevocationCacheTTL. It
+// is local to the instance, so a suspension made elsewhere is only seen
+// once the cached answer expires.
+var suspensionCache = newFlagCache(10000)
+
+// IsUserSuspended reports whether the account is suspended. Unknown
+// accounts are not suspended. The answer may be up to RevocationCacheTTL
+// old when the account was changed through another instance.
+func IsUserSuspended(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	key := userID.Hex()
+	if suspended, ok := suspensionCache.get(key); ok {
+		return suspended, nil
+	}
+
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+	var user models.User
+	err := users.FindOne(ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"suspended": 1}),
+	).Decode(&user)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+
+	if ttl := negativeCacheTTL(); ttl > 0 {
+		suspensionCache.set(key, user.Suspended, time.Now().Add(ttl))
+	}
+	return user.Suspended, nil
+}
+
+// SetUserSuspended suspends or reinstates an account. Suspending also ends
+// every session and revokes their access tokens. Returns mongo.ErrNoDocuments
+// when the user does not exist.
+func SetUserSuspended(ctx context.Context, userID primitive.ObjectID, suspended bool, reason string) error {
+	users := database.GetCollection(database.DbName(), database.UsersCollection)
+
+	now := time.Now()
+	var update bson.M
+	if suspended {
+		update = bson.M{"$set": bson.M{
+			"suspended":        true,
+			"suspendedAt":      now,
+			"suspensionReason": reason,
+			"updatedAt":        now,
+		}}
+	} else {
+		update = bson.M{
+			"$set":   bson.M{"suspended": false, "updatedAt": now},
+			"$unset": bson.M{"suspendedAt": "", "suspensionReason": ""},
+		}
+	}
+
+	res, err := users.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	suspensionCache.set(userID.Hex(), suspended, now.Add(negativeCacheTTL()))
+
+	if suspended {
+		return RevokeAllSessions(ctx, userID, models.RevocationReasonAdmin)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a signed single-use token for the given purpose.
+// Any earlier token of the same purpose for the user is invalidated.
+func IssueUserToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	sig, err := signUserToken(purpose, raw)
+	if err != nil {
+		return "", err
+	}
+	token := raw + "." + sig
+
+	tokens := database.GetCollection(database.DbName(), database.UserTokensCollection)
+	if _, err := tokens.DeleteMany(ctx, bson.M{"userId": userID, "purpose": purpose}); err != nil {
+		return "", fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	now := time.Now()
+	_, err = tokens.InsertOne(ctx, models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: SHA256Hex(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// FindUserToken verifies a token without using it up, returning its owner.
+// Returns ErrInvalidUserToken for forged, unknown, used or expired tokens.
+func FindUserToken(ctx context.Context, purpose, token string) (primitive.ObjectID, error) {
+	if err := verifyUserTokenSignature(purpose, token); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	tokens := database.GetCollection(database.DbName(), database.UserTokensCollection)
+
+	var stored models.UserToken
+	err := tokens.FindOne(ctx, userTokenFilter(purpose, token)).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, ErrInvalidUserToken
+	}
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("failed to look up token: %w", err)
+	}
+
+	return stored.UserID, nil
+}
+
+// ConsumeUserToken verifies and deletes a token, returning its owner.
+// Returns ErrInvalidUserToken for forged, unknown, used or expired tokens.
+func ConsumeUserToken(ctx context.Context, purpose, token string) (primitive.ObjectID, error) {
+	if err := verifyUserTokenSignature(purpose, token); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	tokens := database.GetCollection(database.DbName(), database.UserTokensCollection)
+
+	var stored models.UserToken
+	err := tokens.FindOneAndDelete(ctx, userTokenFilter(purpose, token)).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, ErrInvalidUserToken
+	}
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	return stored.UserID, nil
+}
+
+// FailUserToken counts a failed attempt made with a token (e.g. a wrong second
+// factor) and deletes the token once maxAttempts is reached. It reports
+// whether the token was deleted.
+func FailUserToken(ctx context.Context, purpose, token string, maxAttempts int) (bool, error) {
+	tokens := database.GetCollection(database.DbName(), database.UserTokensCollection)
+
+	var stored models.UserToken
+	err := tokens.FindOneAndUpdate(ctx,
+		userTokenFilter(purpose, token),
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	if stored.Attempts >= maxAttempts {
+		if _, err := tokens.DeleteOne(ctx, bson.M{"_id": stored.ID}); err != nil {
+			return false, fmt.Errorf("failed to delete token: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func userTokenFilter(purpose, token string) bson.M {
+	return bson.M{
+		"tokenHash": SHA256Hex(token),
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+}
+
+// verifyUserTokenSignature rejects forged tokens before touching the database
+func verifyUserTokenSignature(purpose, token string) error {
+	raw, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidUserToken
+	}
+	expected, err := signUserToken(purpose, raw)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return ErrInvalidUserToken
+	}
+	return nil
+}
+
+// LatestUserTokenIssuedAt returns when the user's current token of the given
+// purpose was issued, or the zero time if there is none
+func LatestUserTokenIssuedAt(ctx context.Context, userID primitive.ObjectID, purpose string) (time.Time, error) {
+	tokens := database.GetCollection(database.DbName(), database.UserTokensCollection)
+
+	var stored models.UserToken
+	err := tokens.FindOne(ctx, bson.M{"userId": userID, "purpose": purpose}).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stored.CreatedAt, nil
+}
+
+// signUserToken binds a raw token to its purpose with an HMAC keyed by the app secret
+func signUserToken(purpose, raw string) (string, error) {
+	cfg := config.AppConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not loaded")
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.Auth.JWTSecret))
+	mac.Write([]byte(purpose + ":" + raw))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
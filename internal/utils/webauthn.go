@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MH-PAVEL/uni-backend-go/internal/config"
+	"github.com/MH-PAVEL/uni-backend-go/internal/database"
+	"github.com/MH-PAVEL/uni-backend-go/internal/models"
+	"github.com/MH-PAVEL/uni-backend-go/internal/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrWebAuthnChallenge = errors.New("unknown or expired webauthn challenge")
+
+// WebAuthnRP returns the relying party configuration
+func WebAuthnRP() webauthn.Config {
+	cfg := config.AppConfig.WebAuthn
+	return webauthn.Config{
+		RPID:    cfg.RPID,
+		RPName:  cfg.RPName,
+		Origins: cfg.Origins,
+		Timeout: cfg.Timeout,
+	}
+}
+
+// BeginWebAuthnCeremony creates and stores a challenge for a ceremony.
+// userID is nil for logins where the user is not known yet.
+func BeginWebAuthnCeremony(ctx context.Context, ceremony string, userID *primitive.ObjectID) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	now := time.Now()
+	doc := models.WebAuthnChallenge{
+		Ceremony:  ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		UserID:    userID,
+		ExpiresAt: now.Add(config.AppConfig.WebAuthn.Timeout),
+		CreatedAt: now,
+	}
+
+	challenges := database.GetCollection(database.DbName(), database.WebAuthnChallengesCollection)
+	if _, err := challenges.InsertOne(ctx, doc); err != nil {
+		return nil, fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// TakeWebAuthnChallenge finds and deletes the challenge echoed in
+// clientDataJSON, so a response can only be verified once
+func TakeWebAuthnChallenge(ctx context.Context, ceremony string, clientDataJSON []byte) ([]byte, *models.WebAuthnChallenge, error) {
+	challenge, err := webauthn.ClientDataChallenge(clientDataJSON)
+	if err != nil {
+		return nil, nil, ErrWebAuthnChallenge
+	}
+
+	challenges := database.GetCollection(database.DbName(), database.WebAuthnChallengesCollection)
+	filter := bson.M{
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"ceremony":  ceremony,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var stored models.WebAuthnChallenge
+	err = challenges.FindOneAndDelete(ctx, filter).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrWebAuthnChallenge
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load challenge: %w", err)
+	}
+	return challenge, &stored, nil
+}
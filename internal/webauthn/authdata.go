@@ -0,0 +1,78 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+	flagExtensionData    = 0x80
+)
+
+// authenticatorData is the parsed authData structure (WebAuthn §6.1)
+type authenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Present only in registration responses
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key
+}
+
+func (a *authenticatorData) UserPresent() bool  { return a.Flags&flagUserPresent != 0 }
+func (a *authenticatorData) UserVerified() bool { return a.Flags&flagUserVerified != 0 }
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+
+	a := &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if a.Flags&flagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("webauthn: attested credential data too short")
+		}
+		a.AAGUID = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, errors.New("webauthn: invalid credential id length")
+		}
+		a.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		// The public key is a single CBOR item; its length is only known
+		// once it has been decoded
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.New("webauthn: invalid credential public key")
+		}
+		a.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if a.Flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.New("webauthn: invalid extension data")
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing data in authenticator data")
+	}
+	return a, nil
+}
@@ -0,0 +1,566 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+var testConfig = Config{
+	RPID:    testRPID,
+	RPName:  "Example",
+	Origins: []string{testOrigin},
+}
+
+// cborItem writes the initial byte and argument of a CBOR item
+func cborItem(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func cborInt(v int64) []byte {
+	if v < 0 {
+		return cborItem(1, uint64(-1-v))
+	}
+	return cborItem(0, uint64(v))
+}
+
+func cborBytes(b []byte) []byte { return append(cborItem(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborItem(3, uint64(len(s))), s...) }
+
+// cborMap encodes alternating keys and values, each already encoded
+func cborMap(kv ...[]byte) []byte {
+	out := cborItem(5, uint64(len(kv)/2))
+	for _, item := range kv {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// softAuthenticator is an in-memory authenticator holding one credential
+type softAuthenticator struct {
+	rpID      string
+	origin    string
+	flags     byte
+	signCount uint32
+	credID    []byte
+	alg       int64
+	ecKey     *ecdsa.PrivateKey
+	edKey     ed25519.PrivateKey
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64) *softAuthenticator {
+	t.Helper()
+	a := &softAuthenticator{
+		rpID:      testRPID,
+		origin:    testOrigin,
+		flags:     flagUserPresent | flagUserVerified,
+		signCount: 1,
+		credID:    make([]byte, 16),
+		alg:       alg,
+	}
+	if _, err := rand.Read(a.credID); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	switch alg {
+	case AlgES256:
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (a *softAuthenticator) coseKey(t *testing.T) []byte {
+	t.Helper()
+	if a.alg == AlgEdDSA {
+		return cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeOKP),
+			cborInt(coseAlgorithm), cborInt(AlgEdDSA),
+			cborInt(coseCurve), cborInt(coseCurveEd25519),
+			cborInt(coseX), cborBytes(a.edKey.Public().(ed25519.PublicKey)),
+		)
+	}
+	pub, err := a.ecKey.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := pub.Bytes() // 0x04 || x || y
+	return cborMap(
+		cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+		cborInt(coseAlgorithm), cborInt(AlgES256),
+		cborInt(coseCurve), cborInt(coseCurveP256),
+		cborInt(coseX), cborBytes(point[1:33]),
+		cborInt(coseY), cborBytes(point[33:]),
+	)
+}
+
+func (a *softAuthenticator) authData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := a.flags
+	if attested {
+		flags |= flagAttestedCredData
+	}
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credID)))
+		data = append(data, a.credID...)
+		data = append(data, a.coseKey(t)...)
+	}
+	return data
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(clientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+	if a.alg == AlgEdDSA {
+		return ed25519.Sign(a.edKey, data)
+	}
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func (a *softAuthenticator) register(t *testing.T, challenge []byte) *RegistrationResponse {
+	t.Helper()
+	resp := &RegistrationResponse{ID: base64.RawURLEncoding.EncodeToString(a.credID), RawID: a.credID, Type: "public-key"}
+	resp.Response.ClientDataJSON = a.clientData(t, "webauthn.create", challenge)
+	resp.Response.AttestationObject = cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authData(t, true)),
+	)
+	return resp
+}
+
+func (a *softAuthenticator) assert(t *testing.T, challenge []byte) *AssertionResponse {
+	t.Helper()
+	resp := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.credID), RawID: a.credID, Type: "public-key"}
+	resp.Response.ClientDataJSON = a.clientData(t, "webauthn.get", challenge)
+	resp.Response.AuthenticatorData = a.authData(t, false)
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	resp.Response.Signature = a.sign(t, append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+	return resp
+}
+
+var testAlgorithms = []struct {
+	name string
+	alg  int64
+}{
+	{"ES256", AlgES256},
+	{"EdDSA", AlgEdDSA},
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(a *softAuthenticator)
+		mutate  func(t *testing.T, resp *RegistrationResponse)
+		wantErr error
+	}{
+		{name: "valid"},
+		{
+			name:    "wrong origin",
+			setup:   func(a *softAuthenticator) { a.origin = "https://evil.example.com" },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "wrong rpIdHash",
+			setup:   func(a *softAuthenticator) { a.rpID = "evil.example.com" },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "user verification missing",
+			setup:   func(a *softAuthenticator) { a.flags = flagUserPresent },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "user presence missing",
+			setup:   func(a *softAuthenticator) { a.flags = flagUserVerified },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "wrong challenge",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				var cd clientData
+				_ = json.Unmarshal(resp.Response.ClientDataJSON, &cd)
+				cd.Challenge = base64.RawURLEncoding.EncodeToString([]byte("another challenge"))
+				resp.Response.ClientDataJSON, _ = json.Marshal(cd)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "assertion client data",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				var cd clientData
+				_ = json.Unmarshal(resp.Response.ClientDataJSON, &cd)
+				cd.Type = "webauthn.get"
+				resp.Response.ClientDataJSON, _ = json.Marshal(cd)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "credential id mismatch",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				resp.RawID = []byte("someone else's credential")
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "truncated attestation object",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				obj := resp.Response.AttestationObject
+				resp.Response.AttestationObject = obj[:len(obj)-10]
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "trailing data after attestation object",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				resp.Response.AttestationObject = append(resp.Response.AttestationObject, 0x00)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "oversized authData length",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				resp.Response.AttestationObject = cborMap(
+					cborText("fmt"), cborText("none"),
+					cborText("attStmt"), cborMap(),
+					cborText("authData"), cborItem(2, 1<<40),
+				)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "oversized map",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				resp.Response.AttestationObject = append(cborItem(5, 1<<32), resp.Response.AttestationObject[1:]...)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "missing authData",
+			mutate: func(t *testing.T, resp *RegistrationResponse) {
+				resp.Response.AttestationObject = cborMap(cborText("fmt"), cborText("none"))
+			},
+			wantErr: ErrInvalidResponse,
+		},
+	}
+
+	for _, alg := range testAlgorithms {
+		for _, tt := range tests {
+			t.Run(alg.name+"/"+tt.name, func(t *testing.T) {
+				a := newSoftAuthenticator(t, alg.alg)
+				if tt.setup != nil {
+					tt.setup(a)
+				}
+				challenge, err := NewChallenge()
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := a.register(t, challenge)
+				if tt.mutate != nil {
+					tt.mutate(t, resp)
+				}
+
+				cred, err := testConfig.VerifyRegistration(challenge, resp)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("got error %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(cred.ID, a.credID) {
+					t.Errorf("credential id = %x, want %x", cred.ID, a.credID)
+				}
+				if !bytes.Equal(cred.PublicKey, a.coseKey(t)) {
+					t.Errorf("public key does not match the authenticator's")
+				}
+				if cred.SignCount != a.signCount {
+					t.Errorf("sign count = %d, want %d", cred.SignCount, a.signCount)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	tests := []struct {
+		name        string
+		storedCount uint32
+		setup       func(a *softAuthenticator)
+		mutate      func(t *testing.T, a *softAuthenticator, resp *AssertionResponse)
+		wantCount   uint32
+		wantErr     error
+	}{
+		{name: "valid", storedCount: 4, setup: func(a *softAuthenticator) { a.signCount = 5 }, wantCount: 5},
+		{name: "counter not kept", storedCount: 0, setup: func(a *softAuthenticator) { a.signCount = 0 }, wantCount: 0},
+		{
+			name:        "counter went backwards",
+			storedCount: 10,
+			setup:       func(a *softAuthenticator) { a.signCount = 5 },
+			wantErr:     ErrSignCount,
+		},
+		{
+			name:        "counter did not move",
+			storedCount: 5,
+			setup:       func(a *softAuthenticator) { a.signCount = 5 },
+			wantErr:     ErrSignCount,
+		},
+		{
+			name:        "counter reset to zero",
+			storedCount: 5,
+			setup:       func(a *softAuthenticator) { a.signCount = 0 },
+			wantErr:     ErrSignCount,
+		},
+		{
+			name:    "wrong origin",
+			setup:   func(a *softAuthenticator) { a.origin = "https://evil.example.com" },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "wrong rpIdHash",
+			setup:   func(a *softAuthenticator) { a.rpID = "evil.example.com" },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:    "user verification missing",
+			setup:   func(a *softAuthenticator) { a.flags = flagUserPresent },
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "registration client data",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				var cd clientData
+				_ = json.Unmarshal(resp.Response.ClientDataJSON, &cd)
+				cd.Type = "webauthn.create"
+				resp.Response.ClientDataJSON, _ = json.Marshal(cd)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "tampered signature",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				resp.Response.Signature[len(resp.Response.Signature)-1] ^= 0xff
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered authenticator data",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				// Bump the counter after signing
+				resp.Response.AuthenticatorData[36]++
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "signed by another key",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				other := newSoftAuthenticator(t, a.alg)
+				clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+				resp.Response.Signature = other.sign(t, append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "truncated authenticator data",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				resp.Response.AuthenticatorData = resp.Response.AuthenticatorData[:36]
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "trailing authenticator data",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				resp.Response.AuthenticatorData = append(resp.Response.AuthenticatorData, 0x00)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "oversized extension data",
+			mutate: func(t *testing.T, a *softAuthenticator, resp *AssertionResponse) {
+				data := resp.Response.AuthenticatorData
+				data[32] |= flagExtensionData
+				resp.Response.AuthenticatorData = append(data, cborItem(5, 1<<32)...)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+	}
+
+	for _, alg := range testAlgorithms {
+		for _, tt := range tests {
+			t.Run(alg.name+"/"+tt.name, func(t *testing.T) {
+				a := newSoftAuthenticator(t, alg.alg)
+				publicKey := a.coseKey(t)
+				if tt.setup != nil {
+					tt.setup(a)
+				}
+				challenge, err := NewChallenge()
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := a.assert(t, challenge)
+				if tt.mutate != nil {
+					tt.mutate(t, a, resp)
+				}
+
+				count, err := testConfig.VerifyAssertion(challenge, resp, publicKey, tt.storedCount)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("got error %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if count != tt.wantCount {
+					t.Errorf("sign count = %d, want %d", count, tt.wantCount)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	deep := append(bytes.Repeat([]byte{0x81}, maxCBORDepth+2), 0x00)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{name: "small int", data: []byte{0x17}, want: int64(23)},
+		{name: "negative int", data: cborInt(-257), want: int64(-257)},
+		{name: "text", data: cborText("none"), want: "none"},
+		{name: "null", data: []byte{0xf6}, want: nil},
+		{name: "empty input", data: nil, wantErr: true},
+		{name: "truncated argument", data: []byte{0x19, 0x01}, wantErr: true},
+		{name: "truncated byte string", data: []byte{0x45, 0x01, 0x02}, wantErr: true},
+		{name: "oversized byte string", data: cborItem(2, 1<<62), wantErr: true},
+		{name: "oversized text", data: append(cborItem(3, 1<<32), "abc"...), wantErr: true},
+		{name: "oversized array", data: append(cborItem(4, 1<<40), 0x00), wantErr: true},
+		{name: "oversized map", data: append(cborItem(5, 1<<40), 0x00, 0x00), wantErr: true},
+		{name: "array shorter than its count", data: []byte{0x83, 0x01, 0x02}, wantErr: true},
+		{name: "integer overflow", data: cborItem(0, 1<<63), wantErr: true},
+		{name: "indefinite length", data: []byte{0x5f, 0x41, 0x00, 0xff}, wantErr: true},
+		{name: "nesting too deep", data: deep, wantErr: true},
+		{name: "byte string map key", data: []byte{0xa1, 0x41, 0x00, 0x00}, wantErr: true},
+		{name: "float", data: []byte{0xf9, 0x3c, 0x00}, wantErr: true},
+		{name: "tag", data: []byte{0xc0, 0x00}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := decodeCBOR(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoded %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejectsMalformedKeys(t *testing.T) {
+	a := newSoftAuthenticator(t, AlgES256)
+	valid := a.coseKey(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", valid[:len(valid)-1]},
+		{"trailing data", append(append([]byte(nil), valid...), 0x00)},
+		{"not a map", cborBytes([]byte("key"))},
+		{"unsupported algorithm", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+			cborInt(coseAlgorithm), cborInt(-35),
+		)},
+		{"point not on curve", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+			cborInt(coseAlgorithm), cborInt(AlgES256),
+			cborInt(coseCurve), cborInt(coseCurveP256),
+			cborInt(coseX), cborBytes(bytes.Repeat([]byte{1}, 32)),
+			cborInt(coseY), cborBytes(bytes.Repeat([]byte{2}, 32)),
+		)},
+		{"short Ed25519 key", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeOKP),
+			cborInt(coseAlgorithm), cborInt(AlgEdDSA),
+			cborInt(coseCurve), cborInt(coseCurveEd25519),
+			cborInt(coseX), cborBytes(make([]byte, 31)),
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCOSEKey(tt.data); err == nil {
+				t.Fatal("expected an error")
+			} else if !strings.HasPrefix(err.Error(), "webauthn:") {
+				t.Errorf("error %q is not a webauthn error", err)
+			}
+		})
+	}
+}